@@ -0,0 +1,61 @@
+package admin_command
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nas-core/nascore/nascore_handler_http/handler_util"
+
+	"github.com/nas-core/nascore/nascore_util/followStartAndCron"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// PathCommand 命令块接口的路径前缀
+const PathCommand = system_config.PrefixAdminApi + "command"
+
+const maxCommandBody = 256 * 1024
+
+// dryRunRequest command 为空时预览当前配置中的命令块
+type dryRunRequest struct {
+	Block   string `json:"block"` // lego、rclone-mount 或 rclone-unmount
+	Command string `json:"command"`
+}
+
+// HandlerCommand 命令块接口，需挂载在 PathCommand+"/" 上
+//
+//	POST /@adminapi/command/dryrun   {"block","command"} 解析命令块但不执行，返回每行展开后的命令、环境变量与提示
+func HandlerCommand(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nsCfg := system_config.CurrentOr(nsCfg)
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, PathCommand), "/")
+		if rest != "dryrun" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req dryRunRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommandBody)).Decode(&req); err != nil {
+			handler_util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json: " + err.Error()})
+			return
+		}
+		result, err := followStartAndCron.DryRunCommandBlock(nsCfg, req.Block, req.Command)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, followStartAndCron.ErrUnknownCommandBlock) {
+				status = http.StatusBadRequest
+			}
+			logger.Warnf("[admin_command] dry run %s err: %v", req.Block, err)
+			handler_util.WriteJSON(w, status, map[string]string{"error": err.Error()})
+			return
+		}
+		handler_util.WriteJSON(w, http.StatusOK, result)
+	}
+}
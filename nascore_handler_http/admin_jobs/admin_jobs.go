@@ -0,0 +1,150 @@
+package admin_jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nas-core/nascore/nascore_handler_http/handler_util"
+
+	"github.com/nas-core/nascore/nascore_util/followStartAndCron"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// PathJobs 计划任务管理接口的路径前缀
+const PathJobs = system_config.PrefixAdminApi + "jobs"
+
+const maxJobBody = 64 * 1024
+
+// HandlerJobs 计划任务管理接口，需挂载在 PathJobs 与 PathJobs+"/" 上
+//
+//	GET    /@adminapi/jobs                        列出所有任务
+//	POST   /@adminapi/jobs                        扩展程序注册任务 {"name","schedule","extension","path"}
+//	GET    /@adminapi/jobs/{name}                 单个任务状态
+//	DELETE /@adminapi/jobs/{name}                 移除扩展程序注册的任务
+//	POST   /@adminapi/jobs/{name}/run|pause|resume
+//	GET    /@adminapi/jobs/{name}/history?n=20     最近的运行记录及输出
+func HandlerJobs(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nsCfg := system_config.CurrentOr(nsCfg)
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, PathJobs), "/")
+		var parts []string
+		if rest != "" {
+			parts = strings.Split(rest, "/")
+		}
+
+		switch {
+		case len(parts) == 0 && r.Method == http.MethodGet:
+			handler_util.WriteJSON(w, http.StatusOK, followStartAndCron.ListJobs(nsCfg))
+
+		case len(parts) == 0 && r.Method == http.MethodPost:
+			var job followStartAndCron.ExtensionJob
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobBody)).Decode(&job); err != nil {
+				handler_util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json: " + err.Error()})
+				return
+			}
+			if job.JobName == "" || job.Extension == "" || job.Path == "" {
+				handler_util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "name, extension and path are required"})
+				return
+			}
+			if _, ok := system_config.ExtensionSocketMap[job.Extension]; !ok {
+				handler_util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown extension " + job.Extension})
+				return
+			}
+			if err := followStartAndCron.RegisterJob(job); err != nil {
+				writeError(w, err)
+				return
+			}
+			logger.Infof("[admin_jobs] extension %s registered job %s (%s)", job.Extension, job.JobName, job.Spec)
+			info, _ := followStartAndCron.GetJob(nsCfg, job.JobName)
+			handler_util.WriteJSON(w, http.StatusCreated, info)
+
+		case len(parts) == 0:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			info, err := followStartAndCron.GetJob(nsCfg, parts[0])
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			handler_util.WriteJSON(w, http.StatusOK, info)
+
+		case len(parts) == 1 && r.Method == http.MethodDelete:
+			if err := followStartAndCron.UnregisterJob(parts[0]); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case len(parts) == 1:
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+		case len(parts) == 2 && parts[1] == "history":
+			if !handler_util.AllowMethod(w, r, http.MethodGet) {
+				return
+			}
+			if _, err := followStartAndCron.GetJob(nsCfg, parts[0]); err != nil {
+				writeError(w, err)
+				return
+			}
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			runs, err := followStartAndCron.JobHistory(parts[0], n)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if runs == nil {
+				runs = []followStartAndCron.JobRun{}
+			}
+			handler_util.WriteJSON(w, http.StatusOK, runs)
+
+		case len(parts) == 2:
+			if !handler_util.AllowMethod(w, r, http.MethodPost) {
+				return
+			}
+			name, action := parts[0], parts[1]
+			var err error
+			switch action {
+			case "run":
+				err = followStartAndCron.RunJobNow(nsCfg, logger, name)
+			case "pause":
+				err = followStartAndCron.PauseJob(name)
+			case "resume":
+				err = followStartAndCron.ResumeJob(name)
+			default:
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				logger.Warnf("[admin_jobs] %s %s err: %v", action, name, err)
+				writeError(w, err)
+				return
+			}
+			info, _ := followStartAndCron.GetJob(nsCfg, name)
+			handler_util.WriteJSON(w, http.StatusOK, info)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, followStartAndCron.ErrJobNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, followStartAndCron.ErrJobExists),
+		errors.Is(err, followStartAndCron.ErrJobRunning),
+		errors.Is(err, followStartAndCron.ErrJobBuiltin):
+		status = http.StatusConflict
+	}
+	handler_util.WriteError(w, status, err)
+}
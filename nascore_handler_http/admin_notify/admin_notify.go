@@ -0,0 +1,45 @@
+package admin_notify
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nas-core/nascore/nascore_handler_http/handler_util"
+
+	"github.com/nas-core/nascore/nascore_util/notify"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// PathNotify 通知接口的路径前缀
+const PathNotify = system_config.PrefixAdminApi + "notify"
+
+// HandlerNotify 通知接口，需挂载在 PathNotify+"/" 上
+//
+//	POST /@adminapi/notify/test/{channel}   向指定渠道发送测试消息，不受去重与限流影响
+func HandlerNotify(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nsCfg := system_config.CurrentOr(nsCfg)
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, PathNotify), "/")
+		channel, ok := strings.CutPrefix(rest, "test/")
+		if !ok || channel == "" || strings.Contains(channel, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if !handler_util.AllowMethod(w, r, http.MethodPost) {
+			return
+		}
+		if err := notify.Test(nsCfg, logger, channel); err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, notify.ErrChannelNotFound) {
+				status = http.StatusNotFound
+			}
+			logger.Warnf("[admin_notify] test channel %s err: %v", channel, err)
+			handler_util.WriteError(w, status, err)
+			return
+		}
+		handler_util.WriteJSON(w, http.StatusOK, map[string]string{"result": "sent"})
+	}
+}
@@ -0,0 +1,180 @@
+package admin_services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_handler_http/handler_util"
+
+	"github.com/nas-core/nascore/nascore_util/exeStart"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// PathServices 服务管理接口的路径前缀
+const PathServices = system_config.PrefixAdminApi + "services"
+
+// serviceView 接口返回的单个服务状态
+type serviceView struct {
+	exeStart.ServiceStatus
+	UptimeSec int64 `json:"uptime_sec"`
+}
+
+func newServiceView(st exeStart.ServiceStatus) serviceView {
+	v := serviceView{ServiceStatus: st}
+	if st.State == exeStart.StateRunning && !st.StartedAt.IsZero() {
+		v.UptimeSec = int64(time.Since(st.StartedAt).Seconds())
+	}
+	return v
+}
+
+// HandlerServices 服务管理接口，需挂载在 PathServices 与 PathServices+"/" 上
+//
+//	GET  /@adminapi/services                      列出所有服务
+//	GET  /@adminapi/services/{name}               单个服务状态
+//	POST /@adminapi/services/{name}/start|stop|restart
+//	GET  /@adminapi/services/{name}/logs?n=100     最近的日志
+//	GET  /@adminapi/services/{name}/logs/stream    通过 Server-Sent Events 持续输出日志
+func HandlerServices(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sv := exeStart.DefaultSupervisor(logger) // 第三方程序在启动与配置重载时注册，这里只读取
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, PathServices), "/")
+		var parts []string
+		if rest != "" {
+			parts = strings.Split(rest, "/")
+		}
+
+		switch {
+		case len(parts) == 0:
+			if !handler_util.AllowMethod(w, r, http.MethodGet) {
+				return
+			}
+			list := sv.List()
+			views := make([]serviceView, 0, len(list))
+			for _, st := range list {
+				views = append(views, newServiceView(st))
+			}
+			handler_util.WriteJSON(w, http.StatusOK, views)
+
+		case len(parts) == 1:
+			if !handler_util.AllowMethod(w, r, http.MethodGet) {
+				return
+			}
+			st, err := sv.Status(parts[0])
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			handler_util.WriteJSON(w, http.StatusOK, newServiceView(st))
+
+		case len(parts) == 2 && parts[1] == "logs":
+			if !handler_util.AllowMethod(w, r, http.MethodGet) {
+				return
+			}
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			if n <= 0 {
+				n = 100
+			}
+			lines, err := sv.Tail(parts[0], n)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			handler_util.WriteJSON(w, http.StatusOK, lines)
+
+		case len(parts) == 3 && parts[1] == "logs" && parts[2] == "stream":
+			if !handler_util.AllowMethod(w, r, http.MethodGet) {
+				return
+			}
+			streamLogs(w, r, sv, parts[0], logger)
+
+		case len(parts) == 2:
+			if !handler_util.AllowMethod(w, r, http.MethodPost) {
+				return
+			}
+			name, action := parts[0], parts[1]
+			var err error
+			switch action {
+			case "start":
+				err = sv.Start(name)
+			case "stop":
+				err = sv.Stop(name)
+			case "restart":
+				err = sv.Restart(name)
+			default:
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				logger.Warnf("[admin_services] %s %s err: %v", action, name, err)
+				writeError(w, err)
+				return
+			}
+			st, _ := sv.Status(name)
+			handler_util.WriteJSON(w, http.StatusOK, newServiceView(st))
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// streamLogs 先输出最近的日志，之后持续推送新日志，直到客户端断开
+func streamLogs(w http.ResponseWriter, r *http.Request, sv *exeStart.Supervisor, name string, logger *zap.SugaredLogger) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	if n <= 0 {
+		n = 100
+	}
+	lines, ch, cancel, err := sv.SubscribeLog(name, n)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	for _, line := range lines {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Debugf("[admin_services] log stream of %s closed", name)
+			return
+		case line := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, exeStart.ErrServiceNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, exeStart.ErrServiceAlreadyRunning):
+		status = http.StatusConflict
+	}
+	handler_util.WriteError(w, status, err)
+}
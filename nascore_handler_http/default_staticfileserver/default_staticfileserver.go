@@ -10,6 +10,7 @@ import (
 
 func Default_staticfileserver_handler(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		nsCfg := system_config.CurrentOr(nsCfg)
 		if !nsCfg.Server.DefaultStaticFileServiceEnable {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte("Default static file server is not enabled"))
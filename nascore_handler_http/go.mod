@@ -3,6 +3,7 @@ module github.com/nas-core/nascore/nascore_handler_http
 go 1.24.5
 
 require (
+	github.com/nas-core/WebUi/pkgs/replacetemplateplaceholders v0.0.0-20250724145305-583285898de9
 	github.com/nas-core/nascore/nascore_util v0.0.0-20250724121857-d772349bafb7
 	go.uber.org/zap v1.27.0
 )
@@ -13,7 +14,6 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.3.0 // indirect
 	github.com/joyanhui/golang-pkgs/pkgs/exePath v0.0.0-20250712102146-8780ed189b72 // indirect
-	github.com/nas-core/WebUi/pkgs/replacetemplateplaceholders v0.0.0-20250724145305-583285898de9 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/sagikazarmark/locafero v0.9.0 // indirect
 	github.com/sourcegraph/conc v0.3.0 // indirect
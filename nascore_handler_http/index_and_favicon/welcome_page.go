@@ -33,6 +33,7 @@ type WelcomePageData struct {
 
 func HanderWellcome(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		nsCfg := system_config.CurrentOr(nsCfg)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 		// 准备数据
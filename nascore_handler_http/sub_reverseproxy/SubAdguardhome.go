@@ -11,16 +11,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// SubAdguardhome 反向代理到 AdGuard.ReverseproxyUrl。后端地址每个请求从最新的配置快照读取，跟随热重载，
+// backEndUrl 不再使用，只为兼容已有的调用保留。
+// AdGuard Home 不由 nascore 启动，没有就绪状态，代理前不检查，后端不可用时由 ReverseProxy 返回 502
 func SubAdguardhome(subPathPrefix string, backEndUrl *string, cfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !cfg.ThirdPartyExt.AdGuard.IsAdGuardProxyEnable {
+		snap := system_config.CurrentOr(cfg)
+		if !snap.ThirdPartyExt.AdGuard.IsAdGuardProxyEnable {
 			http.Error(w, "AdGuard IsAdGuardProxyEnable is not enabled", http.StatusServiceUnavailable)
 			return
 		}
 		originalPath := r.URL.Path                                    // 解析目标 URL
 		targetPath := strings.TrimPrefix(originalPath, subPathPrefix) // 移除前缀
 
-		backendfullURL, err := url.Parse(*backEndUrl) // 拼接目标 URL
+		backendfullURL, err := url.Parse(snap.ThirdPartyExt.AdGuard.ReverseproxyUrl) // 拼接目标 URL，跟随热重载
 		if err != nil {
 			logger.Errorf("get backenURL Parse err: %s", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -6,17 +6,31 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/nas-core/nascore/nascore_handler_http/index_and_favicon"
+
+	"github.com/nas-core/nascore/nascore_util/exeStart"
 	"github.com/nas-core/nascore/nascore_util/system_config"
 
 	"go.uber.org/zap"
 )
 
+// SubReverseproxy 反向代理到 *backEndUrl。配置热重载时发布的是新快照，backEndUrl 指向的值不会变化，
+// 后端地址来自配置并需要跟随热重载时使用 SubReverseproxyFunc
 func SubReverseproxy(subPathPrefix string, backEndUrl *string, cfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
+	return SubReverseproxyFunc(subPathPrefix, "", func(*system_config.SysCfg) string { return *backEndUrl }, cfg, logger, qpsCounter)
+}
+
+// SubReverseproxyFunc 反向代理到 backEndUrl 返回的地址，每个请求以最新的配置快照调用一次。
+// service 为后端对应的 Supervisor 服务名，服务就绪前返回提示页面，为空时不检查
+func SubReverseproxyFunc(subPathPrefix string, service string, backEndUrl func(*system_config.SysCfg) string, cfg *system_config.SysCfg, logger *zap.SugaredLogger, qpsCounter *uint64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if service != "" && renderNotReady(w, service, service, "system.shtml", system_config.CurrentOr(cfg), logger) {
+			return
+		}
 		originalPath := r.URL.Path                                    // 解析目标 URL
 		targetPath := strings.TrimPrefix(originalPath, subPathPrefix) // 移除前缀
 
-		backendfullURL, err := url.Parse(*backEndUrl) // 拼接目标 URL
+		backendfullURL, err := url.Parse(backEndUrl(system_config.CurrentOr(cfg))) // 拼接目标 URL
 		if err != nil {
 			logger.Errorf("get backenURL Parse err: %s", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -33,3 +47,22 @@ func SubReverseproxy(subPathPrefix string, backEndUrl *string, cfg *system_confi
 		proxy.ServeHTTP(w, r)
 	}
 }
+
+// renderNotReady 服务由 Supervisor 托管且就绪探测尚未通过时返回 503 提示页面并返回 true。
+// 未托管的服务没有就绪状态，不拦截
+func renderNotReady(w http.ResponseWriter, service, displayName, gotoLink string, snap *system_config.SysCfg, logger *zap.SugaredLogger) bool {
+	ready, managed := exeStart.DefaultSupervisor(logger).Ready(service)
+	if !managed || ready {
+		return false
+	}
+	w.Header().Set("Retry-After", "5")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	index_and_favicon.RenderPage(w,
+		displayName+" is not ready",
+		displayName+" has been started by nascore but its readiness probe has not passed yet. Please retry in a moment or check the service log.",
+		displayName+" 已由 nascore 启动，但就绪探测尚未通过。请稍后重试或查看服务日志。",
+		gotoLink, "Goto",
+		snap.WebUICdnPrefix,
+	)
+	return true
+}
@@ -0,0 +1,441 @@
+// Package cmdline 解析 AutoMountCommand、AcmeLego.Command 这类多行命令块。
+// 语法是 POSIX shell 的一个子集：单引号、双引号、反斜杠转义、${VAR} 展开、行尾 \ 续行与 # 注释，
+// 不支持管道、重定向与命令替换。export / set 行设置后续命令的环境变量，unset 行移除变量，unset -a 清除此前设置的全部变量；
+// 命令前的 NAME=VALUE 只作用于这一条命令。以 &nascore 结尾的命令在后台运行
+package cmdline
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// BackgroundMarker 命令结尾的后台运行标记
+const BackgroundMarker = "&nascore"
+
+// Command 命令块中的一条命令
+type Command struct {
+	Line       int      // 起始行号，从 1 开始
+	Raw        string   // 原始文本，包含续行
+	Args       []string // 命令及参数，Assign 为 true 时为空
+	Assign     bool     // export / set / unset 或只有 NAME=VALUE 的行，不执行
+	Env        []string // 截至此行累计设置的环境变量 KEY=VALUE，加上命令前的 NAME=VALUE，执行时追加到进程环境之后
+	Unset      []string // 截至此行 unset 的变量名，执行时从继承的进程环境中移除
+	Background bool     // 以 &nascore 结尾
+	Unknown    []string // 未定义而展开为空的变量名
+}
+
+// Options 解析选项
+type Options struct {
+	Vars          map[string]string // 预定义变量，例如 BinPath，优先级低于命令块中 export 的变量
+	KeepBackslash bool              // 反斜杠只用于续行，其它位置按字面保留，用于 Windows 路径
+	NoProcessEnv  bool              // 不从当前进程环境变量中查找 ${VAR}
+}
+
+// ParseError 语法错误，行号与列号从 1 开始
+type ParseError struct {
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+	Msg  string `json:"msg"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+type word struct {
+	text      string
+	quoted    bool // 包含引号，即使为空也保留
+	eq        int  // 第一个 = 的位置，= 之前为不含引号、转义与展开的字面文本时有效，否则为 -1
+	line, col int
+}
+
+// assignment NAME=VALUE 形式的单词
+func (w word) assignment() (name, value string, ok bool) {
+	if w.eq <= 0 || !validName(w.text[:w.eq]) {
+		return "", "", false
+	}
+	return w.text[:w.eq], w.text[w.eq+1:], true
+}
+
+type parser struct {
+	src       []rune
+	pos       int
+	line, col int
+	opts      Options
+	env       []string
+	envIndex  map[string]int
+	unset     []string
+	unknown   []string
+	cmdStart  int
+	cmdLine   int
+	commands  []Command
+}
+
+// Parse 解析命令块。变量在解析时按行依次展开，后面的行可以引用前面 export 的变量
+func Parse(script string, opts Options) ([]Command, error) {
+	p := &parser{src: []rune(script), line: 1, col: 1, opts: opts, envIndex: make(map[string]int)}
+	for p.pos < len(p.src) {
+		if err := p.parseCommand(); err != nil {
+			return nil, err
+		}
+	}
+	return p.commands, nil
+}
+
+func (p *parser) peek(off int) (rune, bool) {
+	if p.pos+off >= len(p.src) {
+		return 0, false
+	}
+	return p.src[p.pos+off], true
+}
+
+func (p *parser) advance() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+	return r
+}
+
+func (p *parser) errorf(line, col int, format string, args ...any) error {
+	return &ParseError{Line: line, Col: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// continuation 当前位置是否为 \ 换行（或 \ \r\n）
+func (p *parser) continuation() bool {
+	if p.src[p.pos] != '\\' {
+		return false
+	}
+	next, ok := p.peek(1)
+	if ok && next == '\r' {
+		next, ok = p.peek(2)
+	}
+	return ok && next == '\n'
+}
+
+func (p *parser) skipContinuation() {
+	p.advance() // \
+	if p.src[p.pos] == '\r' {
+		p.advance()
+	}
+	p.advance() // \n
+}
+
+// parseCommand 解析一条逻辑命令，到换行或结尾为止
+func (p *parser) parseCommand() error {
+	var words []word
+	p.cmdStart, p.cmdLine = p.pos, p.line
+	p.unknown = nil
+	started := false
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '\n':
+			p.advance()
+			return p.finish(words)
+		case r == ' ' || r == '\t' || r == '\r':
+			p.advance()
+		case p.continuation():
+			p.skipContinuation()
+		case r == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.advance()
+			}
+		default:
+			if !started {
+				p.cmdStart, p.cmdLine = p.pos, p.line
+				started = true
+			}
+			w, err := p.parseWord()
+			if err != nil {
+				return err
+			}
+			if w.text != "" || w.quoted {
+				words = append(words, w)
+			}
+		}
+	}
+	return p.finish(words)
+}
+
+func isWordEnd(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
+
+func (p *parser) parseWord() (word, error) {
+	var b strings.Builder
+	quoted, literal, eq := false, true, -1
+	line, col := p.line, p.col
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case isWordEnd(r):
+			return word{b.String(), quoted, eq, line, col}, nil
+		case p.continuation():
+			p.skipContinuation()
+		case r == '\\':
+			literal = false
+			p.advance()
+			if p.opts.KeepBackslash {
+				b.WriteRune('\\')
+			} else if p.pos < len(p.src) {
+				b.WriteRune(p.advance())
+			} else {
+				b.WriteRune('\\')
+			}
+		case r == '\'':
+			quoted, literal = true, false
+			qline, qcol := p.line, p.col
+			p.advance()
+			for {
+				if p.pos >= len(p.src) {
+					return word{}, p.errorf(qline, qcol, "unterminated single quote")
+				}
+				c := p.advance()
+				if c == '\'' {
+					break
+				}
+				b.WriteRune(c)
+			}
+		case r == '"':
+			quoted, literal = true, false
+			if err := p.parseDoubleQuoted(&b); err != nil {
+				return word{}, err
+			}
+		case r == '$':
+			literal = false
+			if err := p.parseDollar(&b); err != nil {
+				return word{}, err
+			}
+		default:
+			if r == '=' && literal && eq < 0 {
+				eq = b.Len()
+			}
+			b.WriteRune(p.advance())
+		}
+	}
+	return word{b.String(), quoted, eq, line, col}, nil
+}
+
+// parseDoubleQuoted 双引号内只有 \ 后跟 $ " \ ` 或换行时是转义，${VAR} 照常展开
+func (p *parser) parseDoubleQuoted(b *strings.Builder) error {
+	line, col := p.line, p.col
+	p.advance()
+	for {
+		if p.pos >= len(p.src) {
+			return p.errorf(line, col, "unterminated double quote")
+		}
+		r := p.src[p.pos]
+		switch {
+		case r == '"':
+			p.advance()
+			return nil
+		case p.continuation():
+			p.skipContinuation()
+		case r == '\\':
+			next, ok := p.peek(1)
+			if ok && !p.opts.KeepBackslash && strings.ContainsRune("$\"\\`", next) {
+				p.advance()
+			}
+			b.WriteRune(p.advance())
+		case r == '$':
+			if err := p.parseDollar(b); err != nil {
+				return err
+			}
+		default:
+			b.WriteRune(p.advance())
+		}
+	}
+}
+
+// parseDollar 展开 ${VAR}，其它形式的 $ 按字面保留
+func (p *parser) parseDollar(b *strings.Builder) error {
+	if next, ok := p.peek(1); !ok || next != '{' {
+		b.WriteRune(p.advance())
+		return nil
+	}
+	line, col := p.line, p.col
+	p.advance()
+	p.advance()
+	var name strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			return p.errorf(line, col, "unterminated ${")
+		}
+		r := p.advance()
+		if r == '}' {
+			break
+		}
+		name.WriteRune(r)
+	}
+	if !validName(name.String()) {
+		return p.errorf(line, col, "invalid variable name %q", name.String())
+	}
+	b.WriteString(p.lookup(name.String()))
+	return nil
+}
+
+func (p *parser) lookup(name string) string {
+	if i, ok := p.envIndex[name]; ok {
+		_, v, _ := strings.Cut(p.env[i], "=")
+		return v
+	}
+	if slices.Contains(p.unset, name) {
+		return ""
+	}
+	if v, ok := p.opts.Vars[name]; ok {
+		return v
+	}
+	if !p.opts.NoProcessEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+	}
+	p.unknown = append(p.unknown, name)
+	return ""
+}
+
+func (p *parser) setEnv(name, value string) {
+	p.unset = slices.DeleteFunc(p.unset, func(n string) bool { return n == name })
+	kv := name + "=" + value
+	if i, ok := p.envIndex[name]; ok {
+		p.env[i] = kv
+		return
+	}
+	p.envIndex[name] = len(p.env)
+	p.env = append(p.env, kv)
+}
+
+// unsetEnv 移除变量，之后的 ${name} 展开为空，也不再从预定义变量与进程环境中查找
+func (p *parser) unsetEnv(name string) {
+	if i, ok := p.envIndex[name]; ok {
+		p.env = slices.Delete(p.env, i, i+1)
+		p.reindex()
+	}
+	if !slices.Contains(p.unset, name) {
+		p.unset = append(p.unset, name)
+	}
+}
+
+// resetEnv 清除此前 export / set / unset 的全部变量，恢复为预定义变量与进程环境
+func (p *parser) resetEnv() {
+	p.env, p.unset = nil, nil
+	p.reindex()
+}
+
+func (p *parser) reindex() {
+	clear(p.envIndex)
+	for i, kv := range p.env {
+		name, _, _ := strings.Cut(kv, "=")
+		p.envIndex[name] = i
+	}
+}
+
+// finish 把一条命令的单词转换为 Command
+func (p *parser) finish(words []word) error {
+	if len(words) == 0 {
+		return nil
+	}
+	cmd := Command{
+		Line:    p.cmdLine,
+		Raw:     strings.TrimSpace(string(p.src[p.cmdStart:p.pos])),
+		Unknown: p.unknown,
+	}
+	first := words[0]
+	var scoped []string // 命令前的 NAME=VALUE
+	for len(words) > 0 {
+		name, value, ok := words[0].assignment()
+		if !ok {
+			break
+		}
+		scoped = append(scoped, name+"="+value)
+		words = words[1:]
+	}
+	if len(words) == 0 { // 只有 NAME=VALUE，与 set 相同
+		cmd.Assign = true
+		for _, kv := range scoped {
+			name, value, _ := strings.Cut(kv, "=")
+			p.setEnv(name, value)
+		}
+		scoped = nil
+	} else if first = words[0]; !first.quoted && first.text == "unset" {
+		cmd.Assign = true
+		for _, w := range words[1:] {
+			switch {
+			case !w.quoted && w.text == "-a":
+				p.resetEnv()
+			case validName(w.text):
+				p.unsetEnv(w.text)
+			default:
+				return p.errorf(w.line, w.col, "invalid variable name %q", w.text)
+			}
+		}
+	} else if !first.quoted && (first.text == "export" || strings.EqualFold(first.text, "set")) {
+		cmd.Assign = true
+		for _, w := range words[1:] {
+			name, value, ok := strings.Cut(w.text, "=")
+			if !validName(name) {
+				return p.errorf(w.line, w.col, "invalid assignment %q", w.text)
+			}
+			if !ok { // export FOO 导出已有的变量
+				value = p.lookup(name)
+			}
+			p.setEnv(name, value)
+		}
+	} else {
+		last := words[len(words)-1]
+		if !last.quoted && last.text == BackgroundMarker {
+			cmd.Background = true
+			words = words[:len(words)-1]
+		}
+		for _, w := range words {
+			cmd.Args = append(cmd.Args, w.text)
+		}
+		if len(cmd.Args) == 0 {
+			return p.errorf(last.line, last.col, "missing command before %s", BackgroundMarker)
+		}
+	}
+	cmd.Env = append([]string(nil), p.env...)
+	for _, kv := range scoped {
+		name, _, _ := strings.Cut(kv, "=")
+		if i, ok := p.envIndex[name]; ok {
+			cmd.Env[i] = kv
+		} else {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Unset = append([]string(nil), p.unset...)
+	p.commands = append(p.commands, cmd)
+	return nil
+}
+
+// Environ 返回执行这条命令使用的环境变量：base 去掉 Unset 中的变量后追加 Env
+func (c Command) Environ(base []string) []string {
+	env := make([]string, 0, len(base)+len(c.Env))
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(c.Unset, name) {
+			env = append(env, kv)
+		}
+	}
+	return append(env, c.Env...)
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package cmdline
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// cmd 测试中比较的字段，不含 Raw
+type cmd struct {
+	Line       int
+	Args       []string
+	Assign     bool
+	Env        []string
+	Unset      []string
+	Background bool
+	Unknown    []string
+}
+
+// project 转换为比较用的字段，空切片统一为 nil
+func project(cmds []Command) []cmd {
+	orNil := func(s []string) []string {
+		if len(s) == 0 {
+			return nil
+		}
+		return s
+	}
+	var out []cmd
+	for _, c := range cmds {
+		out = append(out, cmd{c.Line, orNil(c.Args), c.Assign, orNil(c.Env), orNil(c.Unset), c.Background, orNil(c.Unknown)})
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		opts   Options
+		want   []cmd
+	}{
+		{"plain", "rclone mount a: /mnt --daemon",
+			Options{},
+			[]cmd{{Line: 1, Args: []string{"rclone", "mount", "a:", "/mnt", "--daemon"}}}},
+		{"quotes and escapes", `echo 'a b' "c ${X} \$Y" d\ e a#b ""`,
+			Options{Vars: map[string]string{"X": "1"}},
+			[]cmd{{Line: 1, Args: []string{"echo", "a b", "c 1 $Y", "d e", "a#b", ""}}}},
+		{"dollar without braces is literal", "echo $HOME",
+			Options{},
+			[]cmd{{Line: 1, Args: []string{"echo", "$HOME"}}}},
+		{"continuation and comments", "# comment\ncmd a \\\n  b # trailing\n\nnext\r\n",
+			Options{},
+			[]cmd{{Line: 2, Args: []string{"cmd", "a", "b"}}, {Line: 5, Args: []string{"next"}}}},
+		{"export and set accumulate", "export A=1\nset B=${A}2\necho ${B}",
+			Options{},
+			[]cmd{
+				{Line: 1, Assign: true, Env: []string{"A=1"}},
+				{Line: 2, Assign: true, Env: []string{"A=1", "B=12"}},
+				{Line: 3, Args: []string{"echo", "12"}, Env: []string{"A=1", "B=12"}},
+			}},
+		{"export existing variable", "export V",
+			Options{Vars: map[string]string{"V": "x"}},
+			[]cmd{{Line: 1, Assign: true, Env: []string{"V=x"}}}},
+		{"scoped assignment overrides for one command", "export A=1\nA=2 C=3 run\nrun2",
+			Options{},
+			[]cmd{
+				{Line: 1, Assign: true, Env: []string{"A=1"}},
+				{Line: 2, Args: []string{"run"}, Env: []string{"A=2", "C=3"}},
+				{Line: 3, Args: []string{"run2"}, Env: []string{"A=1"}},
+			}},
+		{"bare assignment is set", "A=1",
+			Options{},
+			[]cmd{{Line: 1, Assign: true, Env: []string{"A=1"}}}},
+		{"unset hides predefined variables", "unset V\necho ${V}",
+			Options{Vars: map[string]string{"V": "v"}},
+			[]cmd{
+				{Line: 1, Assign: true, Unset: []string{"V"}},
+				{Line: 2, Args: []string{"echo"}, Unset: []string{"V"}},
+			}},
+		{"unset -a resets", "export A=1\nunset A\nunset -a\necho ${A}",
+			Options{},
+			[]cmd{
+				{Line: 1, Assign: true, Env: []string{"A=1"}},
+				{Line: 2, Assign: true, Unset: []string{"A"}},
+				{Line: 3, Assign: true},
+				{Line: 4, Args: []string{"echo"}, Unknown: []string{"A"}},
+			}},
+		{"background", "server --port 1 &nascore\necho '&nascore'",
+			Options{},
+			[]cmd{
+				{Line: 1, Args: []string{"server", "--port", "1"}, Background: true},
+				{Line: 2, Args: []string{"echo", "&nascore"}},
+			}},
+		{"keep backslash", `C:\bin\rclone.exe mount "D:\data"`,
+			Options{KeepBackslash: true},
+			[]cmd{{Line: 1, Args: []string{`C:\bin\rclone.exe`, "mount", `D:\data`}}}},
+		{"unknown variable", "echo ${MISSING}x",
+			Options{},
+			[]cmd{{Line: 1, Args: []string{"echo", "x"}, Unknown: []string{"MISSING"}}}},
+		{"empty", "\n  # only comments\n", Options{}, nil},
+	}
+	for _, tt := range tests {
+		tt.opts.NoProcessEnv = true
+		got, err := Parse(tt.script, tt.opts)
+		if err != nil {
+			t.Errorf("%s: Parse err: %v", tt.name, err)
+			continue
+		}
+		if p := project(got); !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("%s: Parse(%q)\n got %+v\nwant %+v", tt.name, tt.script, p, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		script    string
+		line, col int
+		msg       string
+	}{
+		{"echo 'abc", 1, 6, "unterminated single quote"},
+		{"ok\necho \"abc", 2, 6, "unterminated double quote"},
+		{"echo ${A", 1, 6, "unterminated ${"},
+		{"echo ${A\n}", 1, 6, "unterminated ${"},
+		{"echo ${1A}", 1, 6, `invalid variable name "1A"`},
+		{"export 1A=2", 1, 8, `invalid assignment "1A=2"`},
+		{"unset 9", 1, 7, `invalid variable name "9"`},
+		{"  &nascore", 1, 3, "missing command before &nascore"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.script, Options{NoProcessEnv: true})
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) err = %v, want ParseError", tt.script, err)
+			continue
+		}
+		if perr.Line != tt.line || perr.Col != tt.col || perr.Msg != tt.msg {
+			t.Errorf("Parse(%q) = %d:%d %q, want %d:%d %q", tt.script, perr.Line, perr.Col, perr.Msg, tt.line, tt.col, tt.msg)
+		}
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	c := Command{Env: []string{"A=2", "C=3"}, Unset: []string{"B"}}
+	got := c.Environ([]string{"A=0", "B=1", "PATH=/bin"})
+	if want := []string{"A=0", "PATH=/bin", "A=2", "C=3"}; !slices.Equal(got, want) {
+		t.Fatalf("Environ = %v, want %v", got, want)
+	}
+}
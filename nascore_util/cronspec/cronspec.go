@@ -0,0 +1,286 @@
+package cronspec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计划任务的时间表
+type Schedule interface {
+	// Next 返回严格晚于 t 的下一次执行时间，找不到时返回零值
+	Next(t time.Time) time.Time
+}
+
+// EverySchedule 固定间隔执行，对应 @every <duration>
+type EverySchedule struct {
+	Every time.Duration
+}
+
+func (s EverySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.Every)
+}
+
+// CronSchedule 标准 cron 表达式，各字段为位掩码
+type CronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+	location                              *time.Location
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	secondBounds = bounds{0, 59, nil}
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse 解析计划任务表达式，支持：
+//   - 5 段：分 时 日 月 周
+//   - 6 段：秒 分 时 日 月 周
+//   - @every 1h30m 固定间隔
+//   - @yearly @monthly @weekly @daily @hourly 等简写
+//
+// 表达式前可加 CRON_TZ=Asia/Shanghai 或 TZ=Asia/Shanghai 指定时区，否则使用 loc，loc 为 nil 时使用本地时区
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty cron spec")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tzPart, rest, _ := strings.Cut(spec, " ")
+		_, tz, _ := strings.Cut(tzPart, "=")
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("cron spec %q: unknown time zone %q: %w", spec, tz, err)
+		}
+		loc = l
+		spec = strings.TrimSpace(rest)
+	}
+
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("cron spec %q: %w", spec, err)
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("cron spec %q: interval must be at least 1s", spec)
+		}
+		return EverySchedule{Every: every}, nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("cron spec %q: unknown descriptor", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron spec %q: expected 5 or 6 fields, got %d", spec, len(fields))
+	}
+
+	s := &CronSchedule{location: loc}
+	var err error
+	for i, target := range []struct {
+		bits *uint64
+		b    bounds
+		name string
+	}{
+		{&s.second, secondBounds, "second"},
+		{&s.minute, minuteBounds, "minute"},
+		{&s.hour, hourBounds, "hour"},
+		{&s.dom, domBounds, "day of month"},
+		{&s.month, monthBounds, "month"},
+		{&s.dow, dowBounds, "day of week"},
+	} {
+		*target.bits, err = parseField(fields[i], target.b)
+		if err != nil {
+			return nil, fmt.Errorf("cron spec %q: %s field: %w", spec, target.name, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 { // 7 也表示周日
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = fields[3] == "*" || fields[3] == "?"
+	s.dowStar = fields[5] == "*" || fields[5] == "?"
+	return s, nil
+}
+
+// parseField 解析逗号分隔的单个字段，每一项可以是 * ? n a-b 以及带 /step 的形式
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		var lo, hi uint
+		switch rangePart {
+		case "*", "?":
+			lo, hi = b.min, b.max
+		default:
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseValue(loStr, b); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(hiStr, b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = b.max // n/step 表示从 n 开始到最大值
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("range %q: start is after end", part)
+		}
+		step := uint(1)
+		if hasStep {
+			n, err := strconv.ParseUint(stepPart, 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = uint(n)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if uint(n) < b.min || uint(n) > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", n, b.min, b.max)
+	}
+	return uint(n), nil
+}
+
+// allHours 小时字段为 * 时的位掩码
+const allHours = 1<<24 - 1
+
+// Next 逐级向前推进到满足所有字段的时间，最多搜索 5 年。夏令时切换时按墙上时间处理：
+//   - 开始夏令时跳过的时刻（例如当天不存在的 02:30）当天不执行
+//   - 结束夏令时重复的一小时内，小时字段不是 * 的任务只在第一次经过时执行，小时为 * 的任务按实际时间执行
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+	from := t.In(s.location)
+	next := from
+	for {
+		next = s.next(next)
+		if next.IsZero() {
+			return next
+		}
+		if s.hour == allHours || wallClock(next).After(wallClock(from)) {
+			return next.In(origLoc)
+		}
+		// 重复的一小时内第二次经过同一个墙上时间，继续向后找
+	}
+}
+
+// next 返回严格晚于 t 的下一个满足所有字段的时刻，t 已转换到 s.location。
+// 小时、分钟按实际经过的时间推进，而不是用 time.Date 重新构造，time.Date 在夏令时跳过的时刻会回到同一时刻
+func (s *CronSchedule) next(t time.Time) time.Time {
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + 5
+
+WRAP:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = dayStart(t.Year(), t.Month()+1, 1, s.location)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !s.dayMatches(t) {
+		t = dayStart(t.Year(), t.Month(), t.Day()+1, s.location)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	day := t.Day()
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Day() != day {
+			goto WRAP
+		}
+	}
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for s.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// dayStart 当天的第一个时刻。夏令时在午夜开始的时区中当天没有 00:00，time.Date 可能返回前一天的 23:00
+func dayStart(year int, month time.Month, day int, loc *time.Location) time.Time {
+	want := time.Date(year, month, day, 0, 0, 0, 0, time.UTC) // 规范化溢出的月、日
+	t := time.Date(want.Year(), want.Month(), want.Day(), 0, 0, 0, 0, loc)
+	for t.Day() != want.Day() {
+		t = t.Add(time.Hour)
+	}
+	return t
+}
+
+// wallClock 墙上时间，用于判断重复的一小时内是否第二次经过同一时刻
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// dayMatches 日与周同时限定时满足任意一个即可，与传统 cron 的行为一致
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,140 @@
+package cronspec
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"*/5 * * * *", false},
+		{"0 30 2 * * *", false},
+		{"0 0 1 jan,jul *", false},
+		{"0 9 * * mon-fri", false},
+		{"0 0 * * 7", false},
+		{"@daily", false},
+		{"@every 90m", false},
+		{"CRON_TZ=Asia/Shanghai 0 3 * * *", false},
+		{"", true},
+		{"* * * *", true},
+		{"60 * * * *", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"@every 10ms", true},
+		{"@sometimes", true},
+		{"TZ=Nowhere/City 0 3 * * *", true},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.spec, time.UTC)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) err = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 1, 15, 10, 20, 30, 500, time.UTC) // 周四
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2026, 1, 15, 10, 25, 0, 0, time.UTC)},
+		{"30 * * * * *", time.Date(2026, 1, 15, 10, 21, 30, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon", time.Date(2026, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * fri", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)}, // 日与周任意一个满足
+		{"@every 1h", from.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec, time.UTC)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// nextWithTimeout 夏令时处理出错时 Next 会死循环，不能让测试挂住
+func nextWithTimeout(t *testing.T, s Schedule, from time.Time) time.Time {
+	t.Helper()
+	done := make(chan time.Time, 1)
+	go func() { done <- s.Next(from) }()
+	select {
+	case got := <-done:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Next(%v) did not return", from)
+		return time.Time{}
+	}
+}
+
+func TestNextSpringForward(t *testing.T) {
+	ny := mustLoad(t, "America/New_York") // 2026-03-08 02:00 EST 跳到 03:00 EDT
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"0 3 * * *", time.Date(2026, 3, 8, 1, 30, 0, 0, ny), time.Date(2026, 3, 8, 3, 0, 0, 0, ny)},
+		{"30 2 * * *", time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 9, 2, 30, 0, 0, ny)}, // 当天不存在 02:30
+		{"0 * * * *", time.Date(2026, 3, 8, 1, 10, 0, 0, ny), time.Date(2026, 3, 8, 3, 0, 0, 0, ny)},
+		{"*/30 * * * *", time.Date(2026, 3, 8, 1, 45, 0, 0, ny), time.Date(2026, 3, 8, 3, 0, 0, 0, ny)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec, ny)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		if got := nextWithTimeout(t, s, tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q Next(%v) = %v, want %v", tt.spec, tt.from, got, tt.want)
+		}
+	}
+
+	// 夏令时在午夜开始的时区，当天没有 00:00
+	santiago := mustLoad(t, "America/Santiago") // 2026-09-06 00:00 跳到 01:00
+	s, _ := Parse("0 0 * * *", santiago)
+	got := nextWithTimeout(t, s, time.Date(2026, 9, 5, 12, 0, 0, 0, santiago))
+	if want := time.Date(2026, 9, 7, 0, 0, 0, 0, santiago); !got.Equal(want) {
+		t.Errorf("midnight in Santiago Next = %v, want %v", got, want)
+	}
+}
+
+func TestNextFallBack(t *testing.T) {
+	ny := mustLoad(t, "America/New_York") // 2026-11-01 02:00 EDT 回到 01:00 EST，01:xx 经过两次
+	firstPass := time.Date(2026, 11, 1, 1, 30, 0, 0, ny)
+	secondPass := firstPass.Add(time.Hour)
+	if firstPass.Hour() != secondPass.Hour() {
+		t.Fatalf("test setup: %v and %v should share the wall clock hour", firstPass, secondPass)
+	}
+
+	// 小时固定的任务只执行一次
+	s, _ := Parse("30 1 * * *", ny)
+	if got := nextWithTimeout(t, s, firstPass.Add(-time.Minute)); !got.Equal(firstPass) {
+		t.Errorf("fixed hour first run = %v, want %v", got, firstPass)
+	}
+	if got, want := nextWithTimeout(t, s, firstPass), time.Date(2026, 11, 2, 1, 30, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("fixed hour after first pass = %v, want %v", got, want)
+	}
+
+	// 小时为 * 的任务按实际时间在两次经过时都执行
+	s, _ = Parse("30 * * * *", ny)
+	if got := nextWithTimeout(t, s, firstPass); !got.Equal(secondPass) {
+		t.Errorf("every hour after first pass = %v, want %v", got, secondPass)
+	}
+}
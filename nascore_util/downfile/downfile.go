@@ -1,6 +1,7 @@
 package downfile
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -38,6 +39,11 @@ func getFileNameFromURL(rawurl string) string {
 
 // DownloadFile 下载文件到指定目录，返回实际保存的文件完整路径
 func DownloadFile(urlStr string, saveDir string, saveName string) (string, error) {
+	return DownloadFileContext(context.Background(), urlStr, saveDir, saveName)
+}
+
+// DownloadFileContext 与 DownloadFile 相同，ctx 取消或超时后中止下载
+func DownloadFileContext(ctx context.Context, urlStr string, saveDir string, saveName string) (string, error) {
 	// 创建保存目录如果不存在
 	if _, err := os.Stat(saveDir); os.IsNotExist(err) {
 		err := os.MkdirAll(saveDir, 0755)
@@ -47,7 +53,11 @@ func DownloadFile(urlStr string, saveDir string, saveName string) (string, error
 	}
 
 	// 发起 HTTP GET 请求
-	resp, err := http.Get(urlStr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return "", fmt.Errorf("down file request err: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("down file GET err: %w", err)
 	}
@@ -68,36 +78,42 @@ func DownloadFile(urlStr string, saveDir string, saveName string) (string, error
 		return "", fmt.Errorf("down file get file name err")
 	}
 
-	// 创建保存文件
-	fileSavePath := filepath.Join(saveDir, saveName)
-	out, err := os.Create(fileSavePath)
-	if err != nil {
-		return "", fmt.Errorf("mkfile err: %w", err)
-	}
-	defer out.Close()
-
 	// 检查 HTTP 状态码
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("down file HTTP err core: %d", resp.StatusCode)
 	}
 
-	// 将响应体的数据写入文件
-	_, err = io.Copy(out, resp.Body)
+	// 先写入同目录下的临时文件，完整写入后再改名替换，中途取消或超时不会留下不完整的文件
+	fileSavePath := filepath.Join(saveDir, saveName)
+	out, err := os.CreateTemp(saveDir, "."+saveName+".*.tmp")
 	if err != nil {
-		return "", fmt.Errorf("down file write err: %w", err)
+		return "", fmt.Errorf("mkfile err: %w", err)
 	}
+	tmpPath := out.Name()
+	defer os.Remove(tmpPath) // 改名成功后不存在，删除失败可以忽略
 
-	// 查看文件信息
-	fileInfo, err := os.Stat(fileSavePath)
-	if err != nil {
-		return "", fmt.Errorf("down file get file stat err: %w", err)
+	if _, err = io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return "", fmt.Errorf("down file write err: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("down file write err: %w", err)
+	}
+	mode := os.FileMode(0644) // 与 os.Create 创建的文件一致，已有文件时保留其权限
+	if fi, err := os.Stat(fileSavePath); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return "", fmt.Errorf("down file chmod err: %w", err)
 	}
-	fmt.Printf("down file size: %d bit\n", fileInfo.Size())
+	if err := os.Rename(tmpPath, fileSavePath); err != nil {
+		return "", fmt.Errorf("down file rename err: %w", err)
+	}
+
 	// 真实路径
 	absPath, err := filepath.Abs(fileSavePath)
 	if err != nil {
 		return "", fmt.Errorf("down file get file abs path err: %w", err)
 	}
-	fmt.Printf("down file abs path: %s file size: %d bit\n", absPath, fileInfo.Size())
 	return absPath, nil
 }
@@ -0,0 +1,151 @@
+package eventbus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 事件类型，按 <对象>.<动作> 命名，订阅时可用 path.Match 风格的通配符，例如 service.*
+const (
+	TypeServiceStarted   = "service.started"
+	TypeServiceExited    = "service.exited"    // 进程退出，Data 中 failed 表示是否异常退出
+	TypeServiceGaveUp    = "service.gave_up"   // 连续重启次数超过上限，不再重启
+	TypeServiceUnhealthy = "service.unhealthy" // 存活探针连续失败，进程被结束
+
+	TypeJobSucceeded = "job.succeeded"
+	TypeJobFailed    = "job.failed"
+	TypeJobTimeout   = "job.timeout"
+
+	TypeConfigReloaded     = "config.reloaded"
+	TypeConfigReloadFailed = "config.reload_failed"
+
+	TypeSubscriptionRefreshed     = "subscription.refreshed"
+	TypeSubscriptionRefreshFailed = "subscription.refresh_failed"
+
+	TypeCertExpiring = "cert.expiring" // 证书即将过期，Data 中 domain、expires_at、days_left
+)
+
+// Event 事件
+type Event struct {
+	ID     string         `json:"id"`
+	Type   string         `json:"type"`
+	Source string         `json:"source"` // 发布方，例如 supervisor、scheduler
+	Time   time.Time      `json:"time"`
+	Data   map[string]any `json:"data,omitempty"`
+}
+
+// Handler 事件处理函数，在订阅者自己的 goroutine 中按发布顺序调用
+type Handler func(Event)
+
+const subscriberBuffer = 256
+
+type subscriber struct {
+	pattern string
+	ch      chan Event
+	done    chan struct{}
+	onDrop  Handler
+}
+
+// Bus 进程内的发布/订阅总线。发布不会阻塞，订阅者处理不过来时丢弃事件
+type Bus struct {
+	mu      sync.RWMutex
+	subs    map[*subscriber]struct{}
+	dropped atomic.Uint64
+}
+
+// New 创建事件总线
+func New() *Bus {
+	return &Bus{subs: make(map[*subscriber]struct{})}
+}
+
+var defaultBus = New()
+
+// Default 返回全局事件总线
+func Default() *Bus {
+	return defaultBus
+}
+
+// Subscribe 订阅类型匹配 pattern 的事件，pattern 为空或 * 表示全部事件。调用返回的函数取消订阅
+func (b *Bus) Subscribe(pattern string, h Handler) (unsubscribe func()) {
+	return b.SubscribeWithDrop(pattern, h, nil)
+}
+
+// SubscribeWithDrop 与 Subscribe 相同，订阅者处理不过来而丢弃事件时调用 onDrop。
+// onDrop 在 Publish 中同步调用，应尽快返回
+func (b *Bus) SubscribeWithDrop(pattern string, h, onDrop Handler) (unsubscribe func()) {
+	sub := &subscriber{pattern: pattern, ch: make(chan Event, subscriberBuffer), done: make(chan struct{}), onDrop: onDrop}
+	go func() {
+		defer close(sub.done)
+		for e := range sub.ch {
+			h(e)
+		}
+	}()
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			close(sub.ch)
+			b.mu.Unlock()
+			<-sub.done
+		})
+	}
+}
+
+// Publish 发布事件，未设置的 ID 与 Time 会自动补全
+func (b *Bus) Publish(e Event) {
+	if e.ID == "" {
+		e.ID = newEventID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if !Match(sub.pattern, e.Type) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			b.dropped.Add(1)
+			if sub.onDrop != nil {
+				sub.onDrop(e)
+			}
+		}
+	}
+}
+
+// Dropped 返回因订阅者处理不过来而丢弃的事件数
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Publish 向全局事件总线发布事件
+func Publish(typ, source string, data map[string]any) {
+	defaultBus.Publish(Event{Type: typ, Source: source, Data: data})
+}
+
+// Match 判断事件类型是否匹配 pattern
+func Match(pattern, typ string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	ok, err := path.Match(pattern, typ)
+	return err == nil && ok
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,276 @@
+package eventbus
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookTimeout = 10 * time.Second
+	webhookBackoffInitial = time.Second
+	webhookBackoffMax     = 30 * time.Second
+	webhookQueueSize      = 256 // 每个 webhook 等待投递的事件数上限，超出时直接写入死信日志
+
+	HeaderEvent     = "X-Nascore-Event"
+	HeaderDelivery  = "X-Nascore-Delivery"
+	HeaderTimestamp = "X-Nascore-Timestamp"
+	HeaderSignature = "X-Nascore-Signature" // sha256=<hex>，对 "<timestamp>.<body>" 计算 HMAC-SHA256
+)
+
+// Sign 计算 webhook 签名，接收方用同样的 secret 计算并比较即可验证来源
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errNotDelivered 事件在投递前被丢弃，写入死信日志时使用
+var errNotDelivered = errors.New("dropped before delivery, the webhook is too slow")
+
+// WebhookSink 把事件以 JSON POST 到配置的 URL，失败时按指数退避重试，最终失败的事件写入死信日志。
+// 事件经 Enqueue 进入自己的队列，由单独的 goroutine 按顺序投递，慢的接收方不会拖住事件总线
+type WebhookSink struct {
+	cfg        system_config.WebhookStru
+	secret     []byte
+	client     *http.Client
+	deadLetter string
+	logger     *zap.SugaredLogger
+	backoff    time.Duration // 第一次重试前的等待时间，之后每次翻倍
+
+	queue     chan Event
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// NewWebhookSink 创建 webhook 投递器，secret 用于签名
+func NewWebhookSink(cfg system_config.WebhookStru, secret []byte, deadLetter string, logger *zap.SugaredLogger) *WebhookSink {
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookSink{
+		cfg:        cfg,
+		secret:     secret,
+		client:     &http.Client{Timeout: timeout},
+		deadLetter: deadLetter,
+		logger:     logger,
+		backoff:    webhookBackoffInitial,
+		queue:      make(chan Event, webhookQueueSize),
+		done:       make(chan struct{}),
+	}
+}
+
+// Enqueue 把事件放入投递队列，不会阻塞。第一次调用时启动投递 goroutine，队列已满时事件写入死信日志
+func (w *WebhookSink) Enqueue(e Event) {
+	w.startOnce.Do(func() {
+		go func() {
+			defer close(w.done)
+			for e := range w.queue {
+				w.Deliver(e)
+			}
+		}()
+	})
+	select {
+	case w.queue <- e:
+	default:
+		w.Drop(e)
+	}
+}
+
+// Drop 记录未能投递的事件，用于队列已满或事件总线丢弃事件时
+func (w *WebhookSink) Drop(e Event) {
+	w.logger.Warnf("[webhook] %s dropped %s %s: %v", w.cfg.Name, e.Type, e.ID, errNotDelivered)
+	w.writeDeadLetter(e, 0, errNotDelivered)
+}
+
+// Close 停止接收事件，等待队列中已有的事件投递完成。Close 之后不能再调用 Enqueue
+func (w *WebhookSink) Close() {
+	w.closeOnce.Do(func() {
+		w.startOnce.Do(func() { close(w.done) }) // 从未启动过投递 goroutine
+		close(w.queue)
+		<-w.done
+	})
+}
+
+// Wants 事件类型是否在该 webhook 的订阅范围内
+func (w *WebhookSink) Wants(typ string) bool {
+	if len(w.cfg.Events) == 0 {
+		return true
+	}
+	for _, p := range w.cfg.Events {
+		if Match(p, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliver 投递事件，重试用尽后写入死信日志并返回最后一次的错误
+func (w *WebhookSink) Deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	retries := w.cfg.MaxRetries
+	if retries <= 0 {
+		retries = defaultWebhookRetries
+	}
+	backoff := w.backoff
+	var lastErr error
+	attempts := 0
+	for attempts <= retries {
+		attempts++
+		retry, err := w.post(e, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempts > retries {
+			break
+		}
+		w.logger.Debugf("[webhook] %s deliver %s attempt %d err: %v, retry in %s", w.cfg.Name, e.Type, attempts, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, webhookBackoffMax)
+	}
+	w.logger.Warnf("[webhook] %s gave up delivering %s %s after %d attempts: %v", w.cfg.Name, e.Type, e.ID, attempts, lastErr)
+	w.writeDeadLetter(e, attempts, lastErr)
+	return lastErr
+}
+
+// post 发送一次请求，返回错误是否值得重试
+func (w *WebhookSink) post(e Event, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, w.cfg.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, e.Type)
+	req.Header.Set(HeaderDelivery, e.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	if len(w.secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(w.secret, ts, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("status %d", resp.StatusCode)
+}
+
+var deadLetterMu sync.Mutex
+
+// writeDeadLetter 以 JSON 行的形式追加到死信日志
+func (w *WebhookSink) writeDeadLetter(e Event, attempts int, err error) {
+	if w.deadLetter == "" {
+		return
+	}
+	line, _ := json.Marshal(map[string]any{
+		"time":     time.Now(),
+		"webhook":  w.cfg.Name,
+		"url":      w.cfg.Url,
+		"attempts": attempts,
+		"error":    err.Error(),
+		"event":    e,
+	})
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(w.deadLetter), 0755); err != nil {
+		w.logger.Errorf("[webhook] dead letter dir err: %v", err)
+		return
+	}
+	f, err := os.OpenFile(w.deadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		w.logger.Errorf("[webhook] open dead letter log err: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// DeadLetterPath 返回死信日志路径
+func DeadLetterPath(nsCfg *system_config.SysCfg) string {
+	if nsCfg.Events.DeadLetterFile != "" {
+		return nsCfg.Events.DeadLetterFile
+	}
+	return system_config.EnsureDirPathSuffix(nsCfg.Server.TempFilePath) + "logs/webhook_dead_letter.log"
+}
+
+var (
+	webhookMu      sync.Mutex
+	webhookApplied *webhookState
+)
+
+type webhookState struct {
+	cfg    system_config.EventsStru
+	secret string
+	dead   string
+	unsubs []func()
+	sinks  []*WebhookSink
+}
+
+// ApplyWebhookConfig 按配置订阅全局事件总线，配置未变化时不做任何事，可在每次热重载后调用
+func ApplyWebhookConfig(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	next := &webhookState{cfg: nsCfg.Events, secret: nsCfg.Events.WebhookSecret, dead: DeadLetterPath(nsCfg)}
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+	if prev := webhookApplied; prev != nil {
+		if reflect.DeepEqual(prev.cfg, next.cfg) && prev.secret == next.secret && prev.dead == next.dead {
+			return
+		}
+		// 取消订阅会等待已排队的事件投递完成，不能阻塞调用方
+		go func() {
+			for _, unsub := range prev.unsubs {
+				unsub()
+			}
+			for _, sink := range prev.sinks {
+				sink.Close()
+			}
+		}()
+	}
+	for _, hook := range next.cfg.Webhooks {
+		if !hook.Enable || hook.Url == "" {
+			continue
+		}
+		sink := NewWebhookSink(hook, []byte(next.secret), next.dead, logger)
+		next.sinks = append(next.sinks, sink)
+		next.unsubs = append(next.unsubs, defaultBus.SubscribeWithDrop("*", func(e Event) {
+			if sink.Wants(e.Type) {
+				sink.Enqueue(e)
+			}
+		}, func(e Event) {
+			if sink.Wants(e.Type) {
+				sink.Drop(e)
+			}
+		}))
+		logger.Debugf("[webhook] %s subscribed to %v", hook.Name, hook.Events)
+	}
+	webhookApplied = next
+}
@@ -0,0 +1,185 @@
+package eventbus
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+func TestSign(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1700000000.{"a":1}`))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := Sign([]byte("secret"), "1700000000", []byte(`{"a":1}`)); got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+}
+
+// newTestSink 创建指向 url 的投递器，重试等待缩短为 1ms，死信日志写入临时目录
+func newTestSink(t *testing.T, url string, retries int) (*WebhookSink, string) {
+	t.Helper()
+	dead := filepath.Join(t.TempDir(), "dead.log")
+	sink := NewWebhookSink(system_config.WebhookStru{Name: "test", Url: url, MaxRetries: retries, TimeoutSec: 5}, []byte("secret"), dead, zap.NewNop().Sugar())
+	sink.backoff = time.Millisecond
+	return sink, dead
+}
+
+func readDeadLetters(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]any
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("dead letter line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestDeliverSigned(t *testing.T) {
+	var got struct {
+		sync.Mutex
+		header http.Header
+		body   []byte
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Lock()
+		defer got.Unlock()
+		got.header = r.Header.Clone()
+		got.body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	sink, dead := newTestSink(t, srv.URL, 0)
+	e := Event{ID: "id1", Type: TypeJobFailed, Source: "scheduler", Time: time.Now(), Data: map[string]any{"job": "backup"}}
+	if err := sink.Deliver(e); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	got.Lock()
+	defer got.Unlock()
+	if got.header.Get(HeaderEvent) != TypeJobFailed || got.header.Get(HeaderDelivery) != "id1" {
+		t.Fatalf("headers = %v", got.header)
+	}
+	want := Sign([]byte("secret"), got.header.Get(HeaderTimestamp), got.body)
+	if !hmac.Equal([]byte(got.header.Get(HeaderSignature)), []byte(want)) {
+		t.Fatalf("signature %q does not verify, want %q", got.header.Get(HeaderSignature), want)
+	}
+	var sent Event
+	if err := json.Unmarshal(got.body, &sent); err != nil || sent.ID != "id1" || sent.Data["job"] != "backup" {
+		t.Fatalf("body = %s, %v", got.body, err)
+	}
+	if lines := readDeadLetters(t, dead); len(lines) != 0 {
+		t.Fatalf("dead letters after success: %v", lines)
+	}
+}
+
+func TestDeliverRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // 依次返回的状态码，用完后重复最后一个
+		retries      int
+		wantAttempts int32
+		wantDead     bool
+	}{
+		{"recovers", []int{500, 503, 200}, 3, 3, false},
+		{"rate limited", []int{429, 200}, 3, 2, false},
+		{"client error is not retried", []int{400}, 3, 1, true},
+		{"gives up", []int{502}, 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses))-1])
+			}))
+			defer srv.Close()
+
+			sink, dead := newTestSink(t, srv.URL, tt.retries)
+			err := sink.Deliver(Event{ID: "id", Type: TypeServiceExited})
+			if (err != nil) != tt.wantDead || attempts.Load() != tt.wantAttempts {
+				t.Fatalf("Deliver err = %v after %d attempts, want dead %v after %d", err, attempts.Load(), tt.wantDead, tt.wantAttempts)
+			}
+			lines := readDeadLetters(t, dead)
+			if !tt.wantDead {
+				if len(lines) != 0 {
+					t.Fatalf("dead letters = %v", lines)
+				}
+				return
+			}
+			if len(lines) != 1 || lines[0]["attempts"] != float64(tt.wantAttempts) || lines[0]["webhook"] != "test" {
+				t.Fatalf("dead letters = %v", lines)
+			}
+		})
+	}
+}
+
+func TestEnqueueDoesNotBlockOnSlowEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	sink, dead := newTestSink(t, srv.URL, 0)
+	defer sink.Close() // 等待队列中的事件投递完，再删除临时目录
+	defer close(release)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < webhookQueueSize+10; i++ { // 第一个事件阻塞在投递中，其余的填满队列后溢出
+			sink.Enqueue(Event{ID: "id", Type: TypeJobSucceeded})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enqueue blocked on a slow endpoint")
+	}
+	if lines := readDeadLetters(t, dead); len(lines) < 9 || lines[0]["error"] != errNotDelivered.Error() {
+		t.Fatalf("dead letters for the overflow = %d", len(lines))
+	}
+}
+
+func TestBusDropWritesDeadLetter(t *testing.T) {
+	bus := New()
+	sink, dead := newTestSink(t, "http://127.0.0.1:0", 0)
+	block := make(chan struct{})
+	unsub := bus.SubscribeWithDrop("*", func(Event) { <-block }, sink.Drop)
+	for i := 0; i < subscriberBuffer+5; i++ { // 处理函数阻塞，缓冲填满后丢弃
+		bus.Publish(Event{Type: TypeConfigReloaded})
+	}
+	close(block)
+	unsub()
+	if bus.Dropped() == 0 {
+		t.Fatal("no events dropped")
+	}
+	if lines := readDeadLetters(t, dead); uint64(len(lines)) != bus.Dropped() {
+		t.Fatalf("dead letters = %d, dropped = %d", len(lines), bus.Dropped())
+	}
+}
@@ -1,12 +1,8 @@
 package exeStart
 
 import (
-	"fmt"
 	"os"
-	"os/exec"
-	"runtime"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/nas-core/nascore/nascore_util/system_config"
 	"go.uber.org/zap"
@@ -18,103 +14,135 @@ var (
 	PidFileDDNSGo   = "nascore_ddnsgo.pid"
 )
 
-// 通用杀死进程
-func killByPidFile(pidFile string, logger *zap.SugaredLogger) {
-	pidData, err := os.ReadFile(pidFile)
-	if err == nil {
-		pid, _ := strconv.Atoi(strings.TrimSpace(string(pidData)))
-		if pid > 0 {
-			if runtime.GOOS == "windows" {
-				exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", pid)).Run()
-			} else {
-				exec.Command("kill", fmt.Sprintf("%d", pid)).Run()
-			}
+// killByPidFile 检查 pid 文件后结束其中记录的进程。只有确认仍是我们启动的进程时才会发送信号，
+// 过期、损坏、pid 已被复用或无法校验启动时间的文件只记录日志后删除
+func killByPidFile(pidFile string, expectedBin string, grace time.Duration, logger *zap.SugaredLogger) PidFileReport {
+	report := InspectPidFile(pidFile, expectedBin)
+	switch {
+	case report.State == PidFileMissing:
+		return report
+	case report.signallable():
+		logger.Debugf("[killByPidFile] %s", report)
+		if err := stopProcess(report.Info.Pid, grace, nil); err != nil {
+			logger.Errorf("[killByPidFile] %s: %v", pidFile, err)
 		}
-	} else {
-		logger.Warn("[killByPidFile]", err.Error())
+	case report.State == PidFileUnverified:
+		logger.Warnf("[killByPidFile] not signalling, %s; stop pid %d manually if it is still ours", report, report.Info.Pid)
+	default:
+		logger.Warnf("[killByPidFile] not signalling, %s", report)
 	}
-	err = os.Remove(pidFile)
-	if err != nil {
+	if err := os.Remove(pidFile); err != nil {
 		logger.Warn("[killByPidFile] [os.remove] err", err.Error())
 	}
+	return report
+}
+
+const (
+	ServiceCaddy2   = "caddy2"
+	ServiceOpenlist = "openlist"
+	ServiceDDNSGo   = "ddnsgo"
+)
+
+// newServiceSpec 按守护配置生成服务描述，pid 文件放在 TempFilePath 下，日志放在 ServiceLogDir 下
+func newServiceSpec(nsCfg *system_config.SysCfg, name, binPath string, args []string, pidFileName string, cfg system_config.SuperviseStru) ServiceSpec {
+	return ServiceSpec{
+		Name:      name,
+		BinPath:   binPath,
+		Args:      args,
+		PidFile:   nsCfg.Server.TempFilePath + pidFileName,
+		Restart:   RestartPolicyFromCfg(cfg),
+		LogFile:   ServiceLogDir(nsCfg) + name + ".log",
+		Log:       LogPolicyFromCfg(cfg),
+		StopGrace: time.Duration(cfg.StopGraceSec) * time.Second,
+		Liveness:  ProbeFromCfg(cfg.Liveness),
+		Readiness: ProbeFromCfg(cfg.Readiness),
+		Limits:    ResourceLimitsFromCfg(cfg.Limits),
+	}
+}
+
+// CommandServiceSpec 命令块中后台命令的服务描述，pid 文件为 TempFilePath 下的 nascore_<name>.pid
+func CommandServiceSpec(nsCfg *system_config.SysCfg, name string, argv []string, env []string, cfg system_config.SuperviseStru) ServiceSpec {
+	spec := newServiceSpec(nsCfg, name, argv[0], argv[1:], "nascore_"+name+".pid", cfg)
+	spec.Env = env
+	return spec
+}
+
+// Caddy2Spec ./caddy run --config Caddyfile
+func Caddy2Spec(nsCfg *system_config.SysCfg) ServiceSpec {
+	c := nsCfg.ThirdPartyExt.Caddy2
+	return newServiceSpec(nsCfg, ServiceCaddy2, c.BinPath, []string{"run", "--config", c.ConfigPath}, PidFileCaddy2, c.Supervise)
+}
+
+// OpenlistSpec ./openlist server --data ./oplist_data
+func OpenlistSpec(nsCfg *system_config.SysCfg) ServiceSpec {
+	c := nsCfg.ThirdPartyExt.Openlist
+	return newServiceSpec(nsCfg, ServiceOpenlist, c.BinPath, []string{"server", "--data", c.DataPath}, PidFileOpenlist, c.Supervise)
+}
+
+// DDNSGoSpec ./ddns-go -c ddnsgo_config.yaml
+func DDNSGoSpec(nsCfg *system_config.SysCfg) ServiceSpec {
+	c := nsCfg.ThirdPartyExt.DdnsGO
+	return newServiceSpec(nsCfg, ServiceDDNSGo, c.BinPath, []string{"-c", c.ConfigFilePath}, PidFileDDNSGo, c.Supervise)
+}
+
+// ThirdPartyServiceSpecs 返回所有由 Supervisor 托管的第三方程序描述，新增程序只需在此追加
+func ThirdPartyServiceSpecs(nsCfg *system_config.SysCfg) []ServiceSpec {
+	return []ServiceSpec{
+		DDNSGoSpec(nsCfg),
+		Caddy2Spec(nsCfg),
+		OpenlistSpec(nsCfg),
+	}
+}
 
+// RegisterThirdPartyServices 按最新配置注册所有第三方程序，不会启动或停止它们
+func RegisterThirdPartyServices(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) *Supervisor {
+	sv := DefaultSupervisor(logger)
+	for _, spec := range ThirdPartyServiceSpecs(nsCfg) {
+		sv.Register(spec)
+	}
+	return sv
+}
+
+// StartSpec 按最新配置注册服务后启动，服务已在运行时先停止
+func StartSpec(spec ServiceSpec, logger *zap.SugaredLogger) error {
+	sv := DefaultSupervisor(logger)
+	sv.Register(spec)
+	if err := sv.Stop(spec.Name); err != nil {
+		return err
+	}
+	return sv.Start(spec.Name)
+}
+
+// StopSpec 停止服务，服务未注册时按 pid 文件结束残留进程
+func StopSpec(spec ServiceSpec, logger *zap.SugaredLogger) {
+	sv := DefaultSupervisor(logger)
+	sv.Register(spec)
+	if err := sv.Stop(spec.Name); err != nil {
+		logger.Warnf("[StopSpec] %s err: %v", spec.Name, err)
+	}
 }
 
 func KillCaddy2(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
-	killByPidFile(nsCfg.Server.TempFilePath+PidFileCaddy2, logger)
+	StopSpec(Caddy2Spec(nsCfg), logger)
 }
 func KillOpenlist(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
-	killByPidFile(nsCfg.Server.TempFilePath+PidFileOpenlist, logger)
+	StopSpec(OpenlistSpec(nsCfg), logger)
 }
 func KillDDNSGo(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
-	killByPidFile(nsCfg.Server.TempFilePath+PidFileDDNSGo, logger)
+	StopSpec(DDNSGoSpec(nsCfg), logger)
 }
 
 // 启动Caddy2
 func StartCaddy2(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) error {
-	pidFile := nsCfg.Server.TempFilePath + PidFileCaddy2
-	cmd := exec.Command(nsCfg.ThirdPartyExt.Caddy2.BinPath, "run", "--config", nsCfg.ThirdPartyExt.Caddy2.ConfigPath)
-	err := cmd.Start()
-	if err != nil {
-		os.Remove(pidFile)
-		logger.Warn("[StartCaddy2] failed: %v", err)
-		return err
-	}
-	pidStr := fmt.Sprintf("%d", cmd.Process.Pid)
-	err = os.WriteFile(pidFile, []byte(pidStr), 0644)
-	if err != nil {
-		logger.Warn("[StartCaddy2] [os.writeFile] err", err.Error())
-	}
-	go func() {
-		cmd.Wait()
-		os.Remove(pidFile)
-	}()
-	logger.Debug("[StartCaddy2] started, pid: %s, pidfile: %s", pidStr, pidFile)
-	return nil
+	return StartSpec(Caddy2Spec(nsCfg), logger)
 }
 
 // 启动Openlist
 func StartOpenlist(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) error {
-	pidFile := nsCfg.Server.TempFilePath + PidFileOpenlist
-	cmd := exec.Command(nsCfg.ThirdPartyExt.Openlist.BinPath, "server", "--data", nsCfg.ThirdPartyExt.Openlist.DataPath)
-	err := cmd.Start()
-	if err != nil {
-		os.Remove(pidFile)
-		logger.Warn("[StartOpenlist] failed: %v", err)
-		return err
-	}
-	pidStr := fmt.Sprintf("%d", cmd.Process.Pid)
-	err = os.WriteFile(pidFile, []byte(pidStr), 0644)
-	if err != nil {
-		logger.Warn("[StartOpenlist] [os.writeFile] err", err.Error())
-	}
-	go func() {
-		cmd.Wait()
-		os.Remove(pidFile)
-	}()
-	logger.Debug("[StartOpenlist] started, pid: %s, pidfile: %s", pidStr, pidFile)
-	return nil
+	return StartSpec(OpenlistSpec(nsCfg), logger)
 }
 
 // 启动DDNSGo
 func StartDDNSGo(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) error {
-	pidFile := nsCfg.Server.TempFilePath + PidFileDDNSGo
-	cmd := exec.Command(nsCfg.ThirdPartyExt.DdnsGO.BinPath, "-c", nsCfg.ThirdPartyExt.DdnsGO.ConfigFilePath)
-	err := cmd.Start()
-	if err != nil {
-		os.Remove(pidFile)
-		logger.Warn("[StartDDNSGo] failed: %v", err)
-		return err
-	}
-	pidStr := fmt.Sprintf("%d", cmd.Process.Pid)
-	err = os.WriteFile(pidFile, []byte(pidStr), 0644)
-	if err != nil {
-		logger.Warn("[StartDDNSGo] [os.writeFile] err", err.Error())
-	}
-	go func() {
-		cmd.Wait()
-		os.Remove(pidFile)
-	}()
-	logger.Debug("[StartDDNSGo] started, pid: %s, pidfile: %s", pidStr, pidFile)
-	return nil
+	return StartSpec(DDNSGoSpec(nsCfg), logger)
 }
@@ -0,0 +1,68 @@
+package exeStart
+
+import "github.com/nas-core/nascore/nascore_util/system_config"
+
+// ResourceLimits 启动第三方程序时应用的资源限制，0 表示不限制或保持默认
+type ResourceLimits struct {
+	MemoryMaxBytes  int64
+	CPUQuotaPercent int
+	NoFile          uint64
+	NProc           uint64
+	Nice            int
+	IONiceClass     int
+	IONiceLevel     int
+	Uid             int
+	Gid             int
+}
+
+// ResourceLimitsFromCfg 将配置文件中的资源限制转换为 ResourceLimits
+func ResourceLimitsFromCfg(cfg system_config.LimitsStru) ResourceLimits {
+	l := ResourceLimits{
+		MemoryMaxBytes:  int64(cfg.MemoryMaxMB) * 1024 * 1024,
+		CPUQuotaPercent: cfg.CPUQuotaPercent,
+		Nice:            cfg.Nice,
+		IONiceClass:     cfg.IONiceClass,
+		IONiceLevel:     cfg.IONiceLevel,
+		Uid:             cfg.Uid,
+		Gid:             cfg.Gid,
+	}
+	if cfg.NoFile > 0 {
+		l.NoFile = uint64(cfg.NoFile)
+	}
+	if cfg.NProc > 0 {
+		l.NProc = uint64(cfg.NProc)
+	}
+	return l
+}
+
+// IsZero 是否没有配置任何限制
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// requested 返回已配置的限制项名称，用于在不支持的平台上给出提示
+func (l ResourceLimits) requested() []string {
+	var names []string
+	if l.MemoryMaxBytes > 0 {
+		names = append(names, "MemoryMaxMB")
+	}
+	if l.CPUQuotaPercent > 0 {
+		names = append(names, "CPUQuotaPercent")
+	}
+	if l.NoFile > 0 {
+		names = append(names, "NoFile")
+	}
+	if l.NProc > 0 {
+		names = append(names, "NProc")
+	}
+	if l.Nice != 0 {
+		names = append(names, "Nice")
+	}
+	if l.IONiceClass != 0 {
+		names = append(names, "IONiceClass")
+	}
+	if l.Uid != 0 || l.Gid != 0 {
+		names = append(names, "Uid/Gid")
+	}
+	return names
+}
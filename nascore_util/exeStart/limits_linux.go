@@ -0,0 +1,288 @@
+//go:build linux
+
+package exeStart
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	cgroupParent   = "nascore" // 所有托管程序的 cgroup 都放在 /sys/fs/cgroup/nascore/ 下
+	ioprioWhoProc  = 1         // IOPRIO_WHO_PROCESS
+	ioprioClassShf = 13        // IOPRIO_CLASS_SHIFT
+)
+
+var cgroupRoot = "/sys/fs/cgroup" // 测试时指向临时目录
+
+// startWithLimits 按资源限制启动 newCmd 创建的进程，返回已启动的 cmd 与未能应用的项。
+// 无法确定运行用户的组时返回错误，不以 root 组启动。各项限制的生效时机：
+//   - Uid/Gid 通过 SysProcAttr.Credential 在 exec 前设置
+//   - Nice 与 IONice 是线程属性，在锁定的线程上设置后由该线程创建子进程，exec 前即已生效，该线程随后退出
+//   - memory/cpu 通过 CLONE_INTO_CGROUP 在创建进程时加入 cgroup；内核低于 5.7 不支持时改为启动后写入 cgroup.procs，
+//     exec 之后到加入之前的极短时间内不受限制
+//   - NoFile 与 NProc 是进程级的，不能只对一个线程设置，启动后立即通过 prlimit 设置，同样有上述的极短间隔
+func startWithLimits(newCmd func() *exec.Cmd, name string, l ResourceLimits) (*exec.Cmd, []string, error) {
+	var warnings []string
+	var cred *syscall.Credential
+	if l.Uid != 0 || l.Gid != 0 {
+		if os.Geteuid() != 0 {
+			warnings = append(warnings, "Uid/Gid requires nascore to run as root, ignored")
+		} else {
+			var err error
+			if cred, err = credential(l.Uid, l.Gid); err != nil {
+				return nil, warnings, err
+			}
+		}
+	}
+
+	cgroupDir := ""
+	if l.MemoryMaxBytes > 0 || l.CPUQuotaPercent > 0 {
+		dir, err := prepareCgroup(name, l)
+		if err != nil {
+			warnings = append(warnings, "cgroup v2 memory/cpu limits unsupported: "+err.Error())
+		} else {
+			cgroupDir = dir
+		}
+	}
+	cgroupFD := -1
+	if cgroupDir != "" {
+		if fd, err := syscall.Open(cgroupDir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0); err == nil {
+			cgroupFD = fd
+			defer syscall.Close(fd)
+		}
+	}
+
+	start := func(intoCgroup bool) (*exec.Cmd, []string, error) {
+		cmd := newCmd()
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Credential = cred
+		if intoCgroup {
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = cgroupFD
+		}
+		threadWarnings, err := startOnPrioritizedThread(cmd, l)
+		return cmd, threadWarnings, err
+	}
+	intoCgroup := cgroupFD >= 0
+	cmd, threadWarnings, err := start(intoCgroup)
+	if err != nil && intoCgroup && cloneIntoCgroupUnsupported(err) {
+		intoCgroup = false
+		cmd, threadWarnings, err = start(false)
+	}
+	warnings = append(warnings, threadWarnings...)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	pid := cmd.Process.Pid
+	if cgroupDir != "" && !intoCgroup {
+		if err := joinCgroup(cgroupDir, pid); err != nil {
+			warnings = append(warnings, "cgroup: "+err.Error())
+		}
+	}
+	if l.NoFile > 0 {
+		if err := prlimit(pid, syscall.RLIMIT_NOFILE, l.NoFile); err != nil {
+			warnings = append(warnings, "NoFile: "+err.Error())
+		}
+	}
+	if l.NProc > 0 {
+		if err := prlimit(pid, rlimitNproc(), l.NProc); err != nil {
+			warnings = append(warnings, "NProc: "+err.Error())
+		}
+	}
+	return cmd, warnings, nil
+}
+
+// cloneIntoCgroupUnsupported 启动失败是否因为内核不支持 clone3 或 CLONE_INTO_CGROUP
+func cloneIntoCgroupUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.E2BIG) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// startOnPrioritizedThread 在锁定的线程上设置 nice 与 IO 优先级后启动 cmd，子进程从该线程继承这两项。
+// 修改过优先级的线程不解锁，goroutine 结束时随之退出，不会影响 nascore 的其它 goroutine
+func startOnPrioritizedThread(cmd *exec.Cmd, l ResourceLimits) ([]string, error) {
+	if l.Nice == 0 && l.IONiceClass == 0 {
+		return nil, cmd.Start()
+	}
+	type result struct {
+		warnings []string
+		err      error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		runtime.LockOSThread()
+		if syscall.Gettid() == os.Getpid() {
+			// 主线程在 goroutine 结束时不会退出，不能修改它的优先级。占住主线程，换一个线程启动
+			warnings, err := startOnPrioritizedThread(cmd, l)
+			runtime.UnlockOSThread()
+			ch <- result{warnings, err}
+			return
+		}
+		var r result
+		changed := false
+		if l.Nice != 0 {
+			if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, l.Nice); err != nil { // who 为 0 时只作用于当前线程
+				r.warnings = append(r.warnings, "Nice: "+err.Error())
+			} else {
+				changed = true
+			}
+		}
+		if l.IONiceClass != 0 {
+			prio := l.IONiceClass<<ioprioClassShf | l.IONiceLevel
+			if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProc, 0, uintptr(prio)); errno != 0 {
+				r.warnings = append(r.warnings, "IONice: "+errno.Error())
+			} else {
+				changed = true
+			}
+		}
+		r.err = cmd.Start()
+		if !changed {
+			runtime.UnlockOSThread()
+		}
+		ch <- r
+	}()
+	r := <-ch
+	return r.warnings, r.err
+}
+
+// joinCgroup 将已启动的进程移入 cgroup
+func joinCgroup(dir string, pid int) error {
+	return os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+}
+
+// releaseLimits 进程退出后删除它的 cgroup 目录。目录中仍有进程（例如脱离进程组的派生进程）时删除失败，保留到下次启动
+func releaseLimits(name string) {
+	os.Remove(filepath.Join(cgroupRoot, cgroupParent, name))
+}
+
+// removeStaleCgroups 删除 /sys/fs/cgroup/nascore/ 下上次运行遗留的空 cgroup，仍有进程的 cgroup 无法删除，会被跳过
+func removeStaleCgroups() {
+	entries, err := os.ReadDir(filepath.Join(cgroupRoot, cgroupParent))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			os.Remove(filepath.Join(cgroupRoot, cgroupParent, e.Name()))
+		}
+	}
+}
+
+// credential 运行用户与组。只配置 Uid 时使用该用户的主组，附加组设置为该用户所属的组，
+// 而不是保留 root 组；只配置 Gid 时附加组只有 Gid
+func credential(uid, gid int) (*syscall.Credential, error) {
+	if uid == 0 {
+		return &syscall.Credential{Gid: uint32(gid), Groups: []uint32{uint32(gid)}}, nil
+	}
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		if gid == 0 {
+			return nil, fmt.Errorf("Uid %d without Gid: look up primary group: %w", uid, err)
+		}
+		return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{uint32(gid)}}, nil
+	}
+	if gid == 0 {
+		primary, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Uid %d: invalid primary group %q", uid, u.Gid)
+		}
+		gid = int(primary)
+	}
+	groups := []uint32{uint32(gid)}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil && uint32(g) != uint32(gid) {
+				groups = append(groups, uint32(g))
+			}
+		}
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}, nil
+}
+
+// prepareCgroup 创建 /sys/fs/cgroup/nascore/<name> 并写入 memory.max 与 cpu.max
+func prepareCgroup(name string, l ResourceLimits) (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+	var controllers []string
+	if l.MemoryMaxBytes > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if l.CPUQuotaPercent > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	available, err := os.ReadFile(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+	for _, c := range controllers {
+		if !strings.Contains(" "+strings.TrimSpace(string(available))+" ", " "+c+" ") {
+			return "", fmt.Errorf("controller %s is not available", c)
+		}
+	}
+
+	parent := filepath.Join(cgroupRoot, cgroupParent)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	enable := "+" + strings.Join(controllers, " +")
+	if err := os.WriteFile(filepath.Join(cgroupRoot, "cgroup.subtree_control"), []byte(enable), 0644); err != nil {
+		return "", fmt.Errorf("enable controllers in %s: %w", cgroupRoot, err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(enable), 0644); err != nil {
+		return "", fmt.Errorf("enable controllers in %s: %w", parent, err)
+	}
+
+	dir := filepath.Join(parent, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// 未配置的一项写回 max，清除之前运行时留下的限制
+	memMax, cpuMax := "max", "max 100000"
+	if l.MemoryMaxBytes > 0 {
+		memMax = fmt.Sprintf("%d", l.MemoryMaxBytes)
+	}
+	if l.CPUQuotaPercent > 0 {
+		cpuMax = fmt.Sprintf("%d 100000", l.CPUQuotaPercent*1000)
+	}
+	for file, value := range map[string]string{"memory.max": memMax, "cpu.max": cpuMax} {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err != nil {
+			continue // 对应控制器未启用
+		}
+		if err := os.WriteFile(path, []byte(value), 0644); err != nil {
+			return "", fmt.Errorf("write %s: %w", file, err)
+		}
+	}
+	return dir, nil
+}
+
+// rlimitNproc RLIMIT_NPROC 未在 syscall 包中导出，mips 系列为 8，其余架构为 6
+func rlimitNproc() int {
+	if strings.HasPrefix(runtime.GOARCH, "mips") {
+		return 8
+	}
+	return 6
+}
+
+// prlimit 同时设置 pid 的软限制和硬限制
+func prlimit(pid int, resource int, value uint64) error {
+	lim := syscall.Rlimit{Cur: value, Max: value}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&lim)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
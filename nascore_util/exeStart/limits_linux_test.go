@@ -0,0 +1,150 @@
+//go:build linux
+
+package exeStart
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCgroupRoot 在临时目录中模拟 cgroup v2 的根目录，name 的 cgroup 预先创建 memory.max 与 cpu.max
+func fakeCgroupRoot(t *testing.T, name string) string {
+	t.Helper()
+	root := t.TempDir()
+	old := cgroupRoot
+	cgroupRoot = root
+	t.Cleanup(func() { cgroupRoot = old })
+	os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpuset cpu io memory pids\n"), 0644)
+	dir := filepath.Join(root, cgroupParent, name)
+	os.MkdirAll(dir, 0755)
+	for _, f := range []string{"memory.max", "cpu.max"} {
+		os.WriteFile(filepath.Join(dir, f), []byte("max"), 0644)
+	}
+	return root
+}
+
+func readTrimmed(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func TestPrepareCgroup(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      ResourceLimits
+		wantControl string
+		wantMem     string
+		wantCPU     string
+	}{
+		{"memory and cpu", ResourceLimits{MemoryMaxBytes: 256 << 20, CPUQuotaPercent: 50}, "+memory +cpu", "268435456", "50000 100000"},
+		{"memory only resets cpu", ResourceLimits{MemoryMaxBytes: 1 << 20}, "+memory", "1048576", "max 100000"},
+		{"cpu over one core", ResourceLimits{CPUQuotaPercent: 200}, "+cpu", "max", "200000 100000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := fakeCgroupRoot(t, "svc")
+			dir, err := prepareCgroup("svc", tt.limits)
+			if err != nil {
+				t.Fatalf("prepareCgroup: %v", err)
+			}
+			if dir != filepath.Join(root, cgroupParent, "svc") {
+				t.Fatalf("dir = %s", dir)
+			}
+			for _, p := range []string{filepath.Join(root, "cgroup.subtree_control"), filepath.Join(root, cgroupParent, "cgroup.subtree_control")} {
+				if got := readTrimmed(t, p); got != tt.wantControl {
+					t.Errorf("%s = %q, want %q", p, got, tt.wantControl)
+				}
+			}
+			if got := readTrimmed(t, filepath.Join(dir, "memory.max")); got != tt.wantMem {
+				t.Errorf("memory.max = %q, want %q", got, tt.wantMem)
+			}
+			if got := readTrimmed(t, filepath.Join(dir, "cpu.max")); got != tt.wantCPU {
+				t.Errorf("cpu.max = %q, want %q", got, tt.wantCPU)
+			}
+		})
+	}
+}
+
+func TestPrepareCgroupUnsupported(t *testing.T) {
+	fakeCgroupRoot(t, "svc")
+	os.WriteFile(filepath.Join(cgroupRoot, "cgroup.controllers"), []byte("pids\n"), 0644)
+	if _, err := prepareCgroup("svc", ResourceLimits{MemoryMaxBytes: 1 << 20}); err == nil {
+		t.Fatal("missing memory controller should fail")
+	}
+	cgroupRoot = t.TempDir()
+	if _, err := prepareCgroup("svc", ResourceLimits{MemoryMaxBytes: 1 << 20}); err == nil {
+		t.Fatal("cgroup v1 or no cgroup fs should fail")
+	}
+}
+
+func TestJoinAndReleaseCgroup(t *testing.T) {
+	root := fakeCgroupRoot(t, "svc")
+	dir := filepath.Join(root, cgroupParent, "svc")
+	if err := joinCgroup(dir, 1234); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTrimmed(t, filepath.Join(dir, "cgroup.procs")); got != "1234" {
+		t.Fatalf("cgroup.procs = %q", got)
+	}
+
+	releaseLimits("svc") // 真实的 cgroup 中仍有进程时删除失败，这里以非空目录模拟
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatal("populated cgroup was removed")
+	}
+	os.RemoveAll(dir)
+	os.Mkdir(dir, 0755)
+	releaseLimits("svc")
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("empty cgroup was not removed")
+	}
+}
+
+func TestRemoveStaleCgroups(t *testing.T) {
+	root := fakeCgroupRoot(t, "busy")
+	parent := filepath.Join(root, cgroupParent)
+	os.Mkdir(filepath.Join(parent, "stale1"), 0755)
+	os.Mkdir(filepath.Join(parent, "stale2"), 0755)
+	os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+memory"), 0644)
+
+	removeStaleCgroups()
+	entries, _ := os.ReadDir(parent)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if strings.Join(left, ",") != "busy,cgroup.subtree_control" {
+		t.Fatalf("left in %s: %v", parent, left)
+	}
+
+	cgroupRoot = filepath.Join(root, "missing")
+	removeStaleCgroups() // 目录不存在时什么都不做
+}
+
+func TestStartWithLimitsNiceBeforeExec(t *testing.T) {
+	var out strings.Builder
+	// 子进程一启动就读取自己的 nice 值，读到配置值说明 exec 之前已经生效
+	cmd, warnings, err := startWithLimits(func() *exec.Cmd {
+		c := exec.Command("sh", "-c", "cut -d' ' -f19 /proc/$$/stat")
+		c.Stdout = &out
+		return c
+	}, "svc", ResourceLimits{Nice: 7})
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("startWithLimits: %v %v", err, warnings)
+	}
+	cmd.Wait()
+	if got := strings.TrimSpace(out.String()); got != "7" {
+		t.Fatalf("child nice = %q, want 7", got)
+	}
+
+	stat := readTrimmed(t, "/proc/self/stat") // 主线程的 nice 不受影响
+	if fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+2:]); fields[16] != "0" {
+		t.Fatalf("nascore nice changed to %s", fields[16])
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package exeStart
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// startWithLimits 非 Linux 系统不支持资源限制，直接启动并返回每个已配置项的提示
+func startWithLimits(newCmd func() *exec.Cmd, name string, l ResourceLimits) (*exec.Cmd, []string, error) {
+	var warnings []string
+	for _, n := range l.requested() {
+		warnings = append(warnings, n+" is not supported on "+runtime.GOOS)
+	}
+	cmd := newCmd()
+	if err := cmd.Start(); err != nil {
+		return nil, warnings, err
+	}
+	return cmd, warnings, nil
+}
+
+func releaseLimits(name string) {}
+
+func removeStaleCgroups() {}
@@ -0,0 +1,176 @@
+package exeStart
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PidFileState pid 文件检查结果
+type PidFileState string
+
+const (
+	PidFileMissing    PidFileState = "missing"    // 文件不存在
+	PidFileCorrupt    PidFileState = "corrupt"    // 内容无法解析
+	PidFileStale      PidFileState = "stale"      // 记录的进程已经不存在
+	PidFileReused     PidFileState = "reused"     // pid 已被其他进程占用
+	PidFileAlive      PidFileState = "alive"      // 进程存在且启动时间、可执行文件均一致
+	PidFileUnverified PidFileState = "unverified" // 进程存在，但当前系统或旧格式文件无法校验
+)
+
+// errProcInfoUnsupported 当前系统无法读取进程启动时间或可执行文件路径
+var errProcInfoUnsupported = errors.New("process info is not supported on this platform")
+
+// PidFileInfo pid 文件中记录的进程信息
+type PidFileInfo struct {
+	Pid       int
+	StartTime string // 进程启动时间，Linux 下为 /proc/<pid>/stat 的 starttime 字段
+	ExePath   string // 进程可执行文件的绝对路径
+	Legacy    bool   // 旧格式，只记录了 pid
+}
+
+// PidFileReport InspectPidFile 的检查报告
+type PidFileReport struct {
+	Path         string
+	State        PidFileState
+	Info         PidFileInfo
+	Detail       string
+	StartMatched bool // 记录的启动时间与进程当前的启动时间一致
+}
+
+// signallable 是否可以向记录的进程发送信号。启动时间一致才能排除 pid 复用，
+// 只有可执行文件无法读取（例如进程以其他用户运行）时仍按启动时间认定
+func (r PidFileReport) signallable() bool {
+	return r.State == PidFileAlive || r.State == PidFileUnverified && r.StartMatched
+}
+
+func (r PidFileReport) String() string {
+	return fmt.Sprintf("pidfile %s: %s (pid %d) %s", r.Path, r.State, r.Info.Pid, r.Detail)
+}
+
+// WritePidFile 写入 pid，并记录进程启动时间和可执行文件路径，用于之后识别 pid 复用
+func WritePidFile(path string, pid int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pid=%d\n", pid)
+	if st, err := processStartTime(pid); err == nil {
+		fmt.Fprintf(&b, "start=%s\n", st)
+	}
+	if exe, err := processExePath(pid); err == nil {
+		fmt.Fprintf(&b, "exe=%s\n", exe)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// ReadPidFile 读取 pid 文件，兼容只有一个数字的旧格式
+func ReadPidFile(path string) (PidFileInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PidFileInfo{}, err
+	}
+	content := strings.TrimSpace(string(data))
+	if pid, err := strconv.Atoi(content); err == nil {
+		if pid <= 0 {
+			return PidFileInfo{}, fmt.Errorf("invalid pid %d", pid)
+		}
+		return PidFileInfo{Pid: pid, Legacy: true}, nil
+	}
+
+	var info PidFileInfo
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			return PidFileInfo{}, fmt.Errorf("unexpected line %q", line)
+		}
+		switch key {
+		case "pid":
+			info.Pid, err = strconv.Atoi(value)
+			if err != nil {
+				return PidFileInfo{}, fmt.Errorf("invalid pid %q", value)
+			}
+		case "start":
+			info.StartTime = value
+		case "exe":
+			info.ExePath = value
+		}
+	}
+	if info.Pid <= 0 {
+		return PidFileInfo{}, fmt.Errorf("missing pid")
+	}
+	return info, nil
+}
+
+// InspectPidFile 检查 pid 文件记录的进程是否仍是当初启动的那个进程。
+// expectedBin 为期望的可执行文件路径，在旧格式文件中用于辅助校验，可为空
+func InspectPidFile(path string, expectedBin string) PidFileReport {
+	report := PidFileReport{Path: path}
+	info, err := ReadPidFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			report.State = PidFileMissing
+			return report
+		}
+		report.State = PidFileCorrupt
+		report.Detail = err.Error()
+		return report
+	}
+	report.Info = info
+
+	if !processAlive(info.Pid) {
+		report.State = PidFileStale
+		report.Detail = "process no longer exists"
+		return report
+	}
+
+	if info.StartTime != "" {
+		st, err := processStartTime(info.Pid)
+		if err == nil && st != info.StartTime {
+			report.State = PidFileReused
+			report.Detail = fmt.Sprintf("start time %s differs from recorded %s", st, info.StartTime)
+			return report
+		}
+		report.StartMatched = err == nil
+	}
+
+	wantExe := info.ExePath
+	if wantExe == "" && expectedBin != "" {
+		wantExe = resolveExePath(expectedBin)
+	}
+	exe, exeErr := processExePath(info.Pid)
+	if wantExe != "" && exeErr == nil && exe != wantExe {
+		report.State = PidFileReused
+		report.Detail = fmt.Sprintf("executable %s differs from expected %s", exe, wantExe)
+		return report
+	}
+
+	if info.StartTime == "" || exeErr != nil || wantExe == "" {
+		report.State = PidFileUnverified
+		if info.Legacy {
+			report.Detail = "legacy pid file without start time"
+		} else if report.StartMatched {
+			report.Detail = "start time matches, executable cannot be checked"
+		} else {
+			report.Detail = "process identity cannot be fully verified on this platform"
+		}
+		return report
+	}
+	report.State = PidFileAlive
+	return report
+}
+
+// resolveExePath 返回可执行文件的绝对真实路径，失败时返回原始路径的绝对路径
+func resolveExePath(bin string) string {
+	abs, err := filepath.Abs(bin)
+	if err != nil {
+		return bin
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
@@ -0,0 +1,93 @@
+package exeStart
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPidFileReportSignallable(t *testing.T) {
+	tests := []struct {
+		report PidFileReport
+		want   bool
+	}{
+		{PidFileReport{State: PidFileAlive, StartMatched: true}, true},
+		{PidFileReport{State: PidFileUnverified, StartMatched: true}, true}, // 可执行文件无法读取
+		{PidFileReport{State: PidFileUnverified, Info: PidFileInfo{Legacy: true}}, false},
+		{PidFileReport{State: PidFileUnverified}, false}, // 当前系统无法读取启动时间
+		{PidFileReport{State: PidFileReused}, false},
+		{PidFileReport{State: PidFileStale}, false},
+		{PidFileReport{State: PidFileCorrupt}, false},
+		{PidFileReport{State: PidFileMissing}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.report.signallable(); got != tt.want {
+			t.Errorf("%+v signallable = %v, want %v", tt.report, got, tt.want)
+		}
+	}
+}
+
+func TestKillByPidFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process identity is only verified on linux")
+	}
+	tests := []struct {
+		name       string
+		write      func(path string, pid int) error
+		wantState  PidFileState
+		wantKilled bool
+	}{
+		{"verified", WritePidFile, PidFileAlive, true},
+		{"legacy pid only", func(path string, pid int) error {
+			return os.WriteFile(path, []byte(fmt.Sprint(pid)), 0644)
+		}, PidFileUnverified, false},
+		{"start time differs", func(path string, pid int) error {
+			return os.WriteFile(path, []byte(fmt.Sprintf("pid=%d\nstart=1\n", pid)), 0644)
+		}, PidFileReused, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("sleep", "30")
+			setProcessGroup(cmd)
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			exited := make(chan struct{})
+			go func() {
+				cmd.Wait()
+				close(exited)
+			}()
+			defer func() {
+				cmd.Process.Kill()
+				<-exited
+			}()
+
+			path := filepath.Join(t.TempDir(), "svc.pid")
+			if err := tt.write(path, cmd.Process.Pid); err != nil {
+				t.Fatal(err)
+			}
+			report := killByPidFile(path, "", time.Second, zap.NewNop().Sugar())
+			if report.State != tt.wantState {
+				t.Fatalf("state = %s (%s), want %s", report.State, report.Detail, tt.wantState)
+			}
+			killed := false
+			select {
+			case <-exited:
+				killed = true
+			case <-time.After(200 * time.Millisecond):
+			}
+			if killed != tt.wantKilled {
+				t.Fatalf("killed = %v, want %v", killed, tt.wantKilled)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatal("pid file was not removed")
+			}
+		})
+	}
+}
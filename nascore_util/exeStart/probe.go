@@ -0,0 +1,156 @@
+package exeStart
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/cmdline"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+// ProbeType 探测方式
+type ProbeType string
+
+const (
+	ProbeHTTP ProbeType = "http" // HTTP GET，返回 2xx/3xx 视为成功
+	ProbeTCP  ProbeType = "tcp"  // TCP 建连成功即视为成功
+	ProbeUnix ProbeType = "unix" // 通过 unix socket 请求 /ping，与扩展程序的检查方式一致
+	ProbeExec ProbeType = "exec" // 执行命令，退出码为 0 视为成功
+)
+
+// Probe 单个健康探测
+type Probe struct {
+	Type             ProbeType
+	Target           string
+	InitialDelay     time.Duration
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+}
+
+// ProbeFromCfg 将配置文件中的探测配置转换为 Probe
+func ProbeFromCfg(cfg system_config.ProbeStru) Probe {
+	p := Probe{
+		Type:             ProbeType(strings.ToLower(strings.TrimSpace(cfg.Type))),
+		Target:           strings.TrimSpace(cfg.Target),
+		InitialDelay:     time.Duration(cfg.InitialDelaySec) * time.Second,
+		Interval:         time.Duration(cfg.IntervalSec) * time.Second,
+		Timeout:          time.Duration(cfg.TimeoutSec) * time.Second,
+		FailureThreshold: cfg.FailureThreshold,
+	}
+	if p.Interval <= 0 {
+		p.Interval = 10 * time.Second
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = 3 * time.Second
+	}
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 3
+	}
+	return p
+}
+
+// Enabled 是否配置了有效的探测
+func (p Probe) Enabled() bool {
+	switch p.Type {
+	case ProbeHTTP, ProbeTCP, ProbeUnix, ProbeExec:
+		return p.Target != ""
+	}
+	return false
+}
+
+// Check 执行一次探测
+func (p Probe) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	switch p.Type {
+	case ProbeHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("http status %d", resp.StatusCode)
+		}
+		return nil
+	case ProbeTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", p.Target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case ProbeUnix:
+		return PingUnixSocket(ctx, p.Target)
+	case ProbeExec:
+		c, err := execProbeCommand(p.Target)
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+		cmd.Env = c.Environ(os.Environ())
+		return cmd.Run()
+	}
+	return fmt.Errorf("unknown probe type %q", p.Type)
+}
+
+// execProbeCommand 按命令块的语法解析 exec 探测的命令，支持引号与 NAME=VALUE 前缀，只能有一条命令
+func execProbeCommand(target string) (cmdline.Command, error) {
+	commands, err := cmdline.Parse(target, cmdline.Options{KeepBackslash: runtime.GOOS == "windows"})
+	if err != nil {
+		return cmdline.Command{}, fmt.Errorf("exec probe: %w", err)
+	}
+	var run []cmdline.Command
+	for _, c := range commands {
+		if !c.Assign {
+			run = append(run, c)
+		}
+	}
+	switch {
+	case len(run) == 0:
+		return cmdline.Command{}, fmt.Errorf("empty exec probe")
+	case len(run) > 1:
+		return cmdline.Command{}, fmt.Errorf("exec probe has %d commands, only one is allowed", len(run))
+	case run[0].Background:
+		return cmdline.Command{}, fmt.Errorf("exec probe cannot run in the background")
+	}
+	return run[0], nil
+}
+
+// PingUnixSocket 通过 unix socket 请求 http://unix/ping，返回 200 视为成功
+func PingUnixSocket(ctx context.Context, socketPath string) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix/ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package exeStart
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExecProbeCommand(t *testing.T) {
+	tests := []struct {
+		target  string
+		args    []string
+		env     []string
+		wantErr bool
+	}{
+		{`curl -sf http://127.0.0.1:9876/`, []string{"curl", "-sf", "http://127.0.0.1:9876/"}, nil, false},
+		{`sh -c 'test -S "/run/app.sock"'`, []string{"sh", "-c", `test -S "/run/app.sock"`}, nil, false},
+		{`TOKEN=a\ b check`, []string{"check"}, []string{"TOKEN=a b"}, false},
+		{"export MODE=ready\ncheck --mode ${MODE}", []string{"check", "--mode", "ready"}, []string{"MODE=ready"}, false},
+		{"", nil, nil, true},
+		{"A=1", nil, nil, true},
+		{"check one\ncheck two", nil, nil, true},
+		{"check &nascore", nil, nil, true},
+		{`check 'unterminated`, nil, nil, true},
+	}
+	for _, tt := range tests {
+		c, err := execProbeCommand(tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("execProbeCommand(%q) err = %v, wantErr %v", tt.target, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (!slices.Equal(c.Args, tt.args) || !slices.Equal(c.Env, tt.env)) {
+			t.Errorf("execProbeCommand(%q) = %q env %q, want %q env %q", tt.target, c.Args, c.Env, tt.args, tt.env)
+		}
+	}
+}
+
+func TestCheckProbeExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	p := Probe{Type: ProbeExec, Target: `sh -c 'test "$X" = "a b"'`, Timeout: 5 * time.Second}
+	if err := p.Check(context.Background()); err == nil {
+		t.Fatal("probe without X should fail")
+	}
+	p.Target = `X='a b' ` + p.Target
+	if err := p.Check(context.Background()); err != nil {
+		t.Fatalf("probe with quoted env prefix: %v", err)
+	}
+}
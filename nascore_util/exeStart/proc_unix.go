@@ -0,0 +1,34 @@
+//go:build !windows
+
+package exeStart
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让子进程成为新进程组的组长，停止时可以连同其派生的子进程一起结束
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup 向 pid 所在的进程组发送信号，pid 不是组长时只向该进程发送
+func signalGroup(pid int, sig syscall.Signal) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// terminateProcess 请求进程组正常退出
+func terminateProcess(pid int) error {
+	return signalGroup(pid, syscall.SIGTERM)
+}
+
+// forceKillProcess 强制结束进程组
+func forceKillProcess(pid int) error {
+	return signalGroup(pid, syscall.SIGKILL)
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package exeStart
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processStartTime 读取 /proc/<pid>/stat 的第 22 个字段 starttime（开机后的时钟滴答数）
+func processStartTime(pid int) (string, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return "", err
+	}
+	// 第 2 个字段 comm 可能包含空格和括号，从最后一个 ')' 之后开始解析
+	idx := strings.LastIndexByte(string(data), ')')
+	if idx < 0 {
+		return "", fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	// ')' 之后第一个字段是第 3 个字段 state，starttime 是第 22 个字段
+	if len(fields) < 20 {
+		return "", fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	return fields[19], nil
+}
+
+// processExePath 读取 /proc/<pid>/exe 指向的可执行文件路径
+func processExePath(pid int) (string, error) {
+	exe, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/exe")
+	if err != nil {
+		return "", err
+	}
+	// 可执行文件在进程运行期间被替换（例如升级）时，内核会追加该后缀
+	return strings.TrimSuffix(exe, " (deleted)"), nil
+}
+
+// processAlive 判断进程是否存在，僵尸进程视为不存在
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	idx := strings.LastIndexByte(string(data), ')')
+	if idx >= 0 && idx+2 < len(data) && data[idx+2] == 'Z' {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package exeStart
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func processStartTime(pid int) (string, error) {
+	return "", errProcInfoUnsupported
+}
+
+func processExePath(pid int) (string, error) {
+	return "", errProcInfoUnsupported
+}
+
+// processAlive 判断进程是否存在
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" { // windows 下 FindProcess 会打开进程句柄，成功即表示进程存在
+		p.Release()
+		return true
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
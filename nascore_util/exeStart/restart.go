@@ -0,0 +1,76 @@
+package exeStart
+
+import (
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+// RestartMode 进程退出后的重启策略
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure"
+	RestartAlways    RestartMode = "always"
+)
+
+// restartResetAfter 进程连续运行超过该时长后，视为稳定运行，重置连续重启计数
+const restartResetAfter = 5 * time.Minute
+
+// RestartPolicy 重启策略与退避参数
+type RestartPolicy struct {
+	Mode           RestartMode
+	MaxRestarts    int // 连续重启次数上限，0 表示不限制
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+}
+
+// RestartPolicyFromCfg 将配置文件中的守护配置转换为 RestartPolicy
+func RestartPolicyFromCfg(cfg system_config.SuperviseStru) RestartPolicy {
+	p := RestartPolicy{
+		Mode:           RestartMode(strings.ToLower(strings.TrimSpace(cfg.RestartPolicy))),
+		MaxRestarts:    cfg.MaxRestarts,
+		BackoffInitial: time.Duration(cfg.BackoffInitialSec) * time.Second,
+		BackoffMax:     time.Duration(cfg.BackoffMaxSec) * time.Second,
+	}
+	switch p.Mode {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		p.Mode = RestartNever
+	}
+	if p.BackoffInitial <= 0 {
+		p.BackoffInitial = time.Second
+	}
+	if p.BackoffMax < p.BackoffInitial {
+		p.BackoffMax = p.BackoffInitial
+	}
+	return p
+}
+
+// shouldRestart 根据退出情况判断是否需要重启，failed 表示非零退出、被信号结束或探测失败
+func (p RestartPolicy) shouldRestart(failed bool) bool {
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+// backoff 计算第 attempt 次重启前的等待时长（attempt 从 1 开始），指数增长并附加 ±20% 抖动
+func (p RestartPolicy) backoff(attempt int) time.Duration {
+	d := p.BackoffInitial
+	for i := 1; i < attempt && d < p.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > p.BackoffMax {
+		d = p.BackoffMax
+	}
+	jitter := time.Duration(float64(d) * 0.2 * (rand.Float64()*2 - 1))
+	return d + jitter
+}
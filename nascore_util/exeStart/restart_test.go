@@ -0,0 +1,105 @@
+package exeStart
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+func TestRestartPolicyFromCfg(t *testing.T) {
+	tests := []struct {
+		cfg  system_config.SuperviseStru
+		want RestartPolicy
+	}{
+		{system_config.SuperviseStru{}, RestartPolicy{Mode: RestartNever, BackoffInitial: time.Second, BackoffMax: time.Second}},
+		{system_config.SuperviseStru{RestartPolicy: " On-Failure ", MaxRestarts: 5, BackoffInitialSec: 2, BackoffMaxSec: 60},
+			RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 5, BackoffInitial: 2 * time.Second, BackoffMax: time.Minute}},
+		{system_config.SuperviseStru{RestartPolicy: "sometimes", BackoffInitialSec: 10, BackoffMaxSec: 5}, // 上限小于初始值时取初始值
+			RestartPolicy{Mode: RestartNever, BackoffInitial: 10 * time.Second, BackoffMax: 10 * time.Second}},
+		{system_config.SuperviseStru{RestartPolicy: "always", BackoffInitialSec: -1},
+			RestartPolicy{Mode: RestartAlways, BackoffInitial: time.Second, BackoffMax: time.Second}},
+	}
+	for _, tt := range tests {
+		if got := RestartPolicyFromCfg(tt.cfg); got != tt.want {
+			t.Errorf("RestartPolicyFromCfg(%+v) = %+v, want %+v", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		mode   RestartMode
+		failed bool
+		want   bool
+	}{
+		{RestartNever, true, false},
+		{RestartNever, false, false},
+		{RestartOnFailure, true, true},
+		{RestartOnFailure, false, false},
+		{RestartAlways, true, true},
+		{RestartAlways, false, true},
+	}
+	for _, tt := range tests {
+		if got := (RestartPolicy{Mode: tt.mode}).shouldRestart(tt.failed); got != tt.want {
+			t.Errorf("%s shouldRestart(failed=%v) = %v, want %v", tt.mode, tt.failed, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RestartPolicy{BackoffInitial: time.Second, BackoffMax: 30 * time.Second}
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second}, // 32s 超过上限
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		lo, hi := tt.base*8/10, tt.base*12/10 // ±20% 抖动
+		for i := 0; i < 50; i++ {
+			if got := p.backoff(tt.attempt); got < lo || got > hi {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, got, lo, hi)
+			}
+		}
+	}
+}
+
+func TestSupervisorGivesUpAfterMaxRestarts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	s := NewSupervisor(zap.NewNop().Sugar())
+	s.Register(ServiceSpec{
+		Name:    "crash",
+		BinPath: "sh",
+		Args:    []string{"-c", "exit 3"},
+		Restart: RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 2, BackoffInitial: 10 * time.Millisecond, BackoffMax: 20 * time.Millisecond},
+	})
+	if err := s.Start("crash"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, _ := s.Status("crash")
+		if st.State == StateFailed && strings.Contains(st.LastError, "gave up") {
+			if st.RestartCount != 2 || st.StartCount != 3 || st.ExitCode != 3 || !st.NextRestartAt.IsZero() {
+				t.Fatalf("status = %+v", st)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("service did not give up, status = %+v", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
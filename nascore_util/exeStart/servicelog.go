@@ -0,0 +1,266 @@
+package exeStart
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/secretmask"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+// LogPolicy 服务输出日志的轮转与内存缓存参数
+type LogPolicy struct {
+	MaxSize    int64         // 单个日志文件字节数上限，0 表示不轮转
+	MaxAge     time.Duration // 轮转文件保留时长，0 表示不按时间清理
+	MaxBackups int           // 轮转文件保留个数，0 表示不按个数清理
+	TailLines  int           // 内存环形缓冲保留的行数
+}
+
+const defaultTailLines = 500
+
+// LogPolicyFromCfg 将配置文件中的守护配置转换为 LogPolicy
+func LogPolicyFromCfg(cfg system_config.SuperviseStru) LogPolicy {
+	p := LogPolicy{
+		MaxSize:    int64(cfg.LogMaxSizeMB) * 1024 * 1024,
+		MaxAge:     time.Duration(cfg.LogMaxAgeDays) * 24 * time.Hour,
+		MaxBackups: cfg.LogMaxBackups,
+		TailLines:  cfg.LogTailLines,
+	}
+	if p.TailLines <= 0 {
+		p.TailLines = defaultTailLines
+	}
+	return p
+}
+
+// ServiceLogDir 返回第三方程序输出日志所在目录，以 / 或 \ 结尾
+func ServiceLogDir(nsCfg *system_config.SysCfg) string {
+	if nsCfg.ThirdPartyExt.ServiceLogDir != "" {
+		return system_config.EnsureDirPathSuffix(nsCfg.ThirdPartyExt.ServiceLogDir)
+	}
+	return nsCfg.Server.TempFilePath + "logs/"
+}
+
+// ServiceLog 把服务的 stdout/stderr 按行写入可轮转的日志文件，并在内存中保留最近的若干行
+type ServiceLog struct {
+	mu     sync.Mutex
+	path   string
+	policy LogPolicy
+	file   *os.File
+	size   int64
+	ring   []string
+	next   int // ring 中下一次写入的位置
+	full   bool
+	subs   map[chan string]struct{}
+}
+
+// NewServiceLog 创建服务日志，path 为空时只保留内存中的最近日志
+func NewServiceLog(path string, policy LogPolicy) *ServiceLog {
+	if policy.TailLines <= 0 {
+		policy.TailLines = defaultTailLines
+	}
+	return &ServiceLog{
+		path:   path,
+		policy: policy,
+		ring:   make([]string, policy.TailLines),
+	}
+}
+
+// reconfigure 切换日志文件与参数，保留内存中的最近日志与订阅。由 Supervisor 在启动进程前调用，
+// 运行中的进程一直写入启动时的文件
+func (l *ServiceLog) reconfigure(path string, policy LogPolicy) {
+	if policy.TailLines <= 0 {
+		policy.TailLines = defaultTailLines
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if path != l.path && l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	l.path = path
+	if policy.TailLines != len(l.ring) {
+		lines := l.tailLocked(policy.TailLines)
+		l.ring = make([]string, policy.TailLines)
+		l.next = copy(l.ring, lines)
+		l.full = l.next == len(l.ring)
+		if l.full {
+			l.next = 0
+		}
+	}
+	l.policy = policy
+}
+
+// Writer 返回一个按行写入本日志的 io.Writer，stream 标记输出来源，例如 stdout
+func (l *ServiceLog) Writer(stream string) *LineWriter {
+	return &LineWriter{log: l, stream: stream}
+}
+
+// Tail 返回最近的 n 行日志，n <= 0 时返回全部缓存的行
+func (l *ServiceLog) Tail(n int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tailLocked(n)
+}
+
+func (l *ServiceLog) tailLocked(n int) []string {
+	var lines []string
+	if l.full {
+		lines = append(lines, l.ring[l.next:]...)
+	}
+	lines = append(lines, l.ring[:l.next]...)
+	if n > 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Close 关闭日志文件
+func (l *ServiceLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *ServiceLog) appendLine(stream, text string) {
+	line := time.Now().Format("2006-01-02 15:04:05") + " [" + stream + "] " + secretmask.String(text) // 程序可能打印收到的密钥
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ring[l.next] = line
+	l.next++
+	if l.next == len(l.ring) {
+		l.next = 0
+		l.full = true
+	}
+	l.writeFileLocked(line + "\n")
+	for ch := range l.subs {
+		select {
+		case ch <- line:
+		default: // 订阅方处理不过来时丢弃，不阻塞服务输出
+		}
+	}
+}
+
+// Subscribe 订阅之后新写入的日志行，调用返回的函数取消订阅
+func (l *ServiceLog) Subscribe() (<-chan string, func()) {
+	ch := make(chan string, 256)
+	l.mu.Lock()
+	if l.subs == nil {
+		l.subs = make(map[chan string]struct{})
+	}
+	l.subs[ch] = struct{}{}
+	l.mu.Unlock()
+	return ch, func() {
+		l.mu.Lock()
+		delete(l.subs, ch)
+		l.mu.Unlock()
+	}
+}
+
+func (l *ServiceLog) writeFileLocked(line string) {
+	if l.path == "" {
+		return
+	}
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+			return
+		}
+		f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		l.file = f
+		if fi, err := f.Stat(); err == nil {
+			l.size = fi.Size()
+		}
+	}
+	if l.policy.MaxSize > 0 && l.size+int64(len(line)) > l.policy.MaxSize && l.size > 0 {
+		l.rotateLocked()
+		if l.file == nil {
+			return
+		}
+	}
+	n, _ := l.file.WriteString(line)
+	l.size += int64(n)
+}
+
+// rotateLocked 将当前日志重命名为带时间戳的备份文件，并清理过期或超出个数的备份
+func (l *ServiceLog) rotateLocked() {
+	l.file.Close()
+	l.file = nil
+	os.Rename(l.path, backupLogPath(l.path, time.Now()))
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		l.file = f
+		l.size = 0
+	}
+
+	backups, _ := filepath.Glob(l.path + ".*")
+	sort.Sort(sort.Reverse(sort.StringSlice(backups))) // 时间戳后缀，新的在前
+	for i, b := range backups {
+		expired := false
+		if l.policy.MaxBackups > 0 && i >= l.policy.MaxBackups {
+			expired = true
+		}
+		if l.policy.MaxAge > 0 {
+			if fi, err := os.Stat(b); err == nil && time.Since(fi.ModTime()) > l.policy.MaxAge {
+				expired = true
+			}
+		}
+		if expired {
+			os.Remove(b)
+		}
+	}
+}
+
+// backupLogPath 返回不与已有备份重名的备份路径。时间戳精确到毫秒，同一毫秒内多次轮转时追加序号，按名称排序即为先后顺序
+func backupLogPath(path string, now time.Time) string {
+	base := path + "." + now.Format("20060102-150405.000")
+	backup := base
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			return backup
+		}
+		backup = fmt.Sprintf("%s-%03d", base, i)
+	}
+}
+
+// LineWriter 将写入的字节按行切分后追加到 ServiceLog
+type LineWriter struct {
+	log    *ServiceLog
+	stream string
+	buf    bytes.Buffer
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(w.buf.Next(idx + 1))
+		w.log.appendLine(w.stream, strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+// Flush 写出缓冲区中尚未以换行结尾的内容
+func (w *LineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.log.appendLine(w.stream, strings.TrimRight(w.buf.String(), "\r\n"))
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,63 @@
+package exeStart
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceLogRotateUniqueBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc.log")
+	l := NewServiceLog(path, LogPolicy{MaxSize: 16})
+	defer l.Close()
+	w := l.Writer("stdout")
+	for i := 0; i < 5; i++ { // 每行都超过 MaxSize，同一秒内轮转多次
+		w.Write([]byte("a line longer than the limit\n"))
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 4 {
+		t.Fatalf("backups = %v, want 4 distinct files", backups)
+	}
+}
+
+func TestBackupLogPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc.log")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	first := backupLogPath(path, now)
+	if !strings.HasSuffix(first, ".20260102-030405.006") {
+		t.Fatalf("backup path = %s", first)
+	}
+	os.WriteFile(first, nil, 0644)
+	second := backupLogPath(path, now)
+	if second == first || second < first {
+		t.Fatalf("second backup = %s, should sort after %s", second, first)
+	}
+}
+
+func TestServiceLogReconfigure(t *testing.T) {
+	dir := t.TempDir()
+	l := NewServiceLog(filepath.Join(dir, "old.log"), LogPolicy{TailLines: 3})
+	defer l.Close()
+	ch, cancel := l.Subscribe()
+	defer cancel()
+	w := l.Writer("stdout")
+	w.Write([]byte("1\n2\n3\n"))
+
+	l.reconfigure(filepath.Join(dir, "new.log"), LogPolicy{TailLines: 2})
+	w.Write([]byte("4\n"))
+	tail := l.Tail(0)
+	if len(tail) != 2 || !strings.HasSuffix(tail[0], "3") || !strings.HasSuffix(tail[1], "4") {
+		t.Fatalf("tail after reconfigure = %q", tail)
+	}
+	if n := len(ch); n != 4 {
+		t.Fatalf("subscriber received %d lines, want 4", n)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "new.log")); !strings.HasSuffix(strings.TrimSpace(string(data)), "4") {
+		t.Fatalf("new.log = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "old.log")); strings.Contains(string(data), "] 4") {
+		t.Fatalf("old.log got lines written after reconfigure: %q", data)
+	}
+}
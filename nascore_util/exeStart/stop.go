@@ -0,0 +1,70 @@
+package exeStart
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+const (
+	defaultStopGrace  = 10 * time.Second
+	killConfirmPeriod = 5 * time.Second
+)
+
+// stopProcess 先请求进程组正常退出，超过 grace 后强制结束，并确认进程已经消失。
+// exited 在进程退出后关闭；为 nil 时通过轮询 pid 判断
+func stopProcess(pid int, grace time.Duration, exited <-chan struct{}) error {
+	if grace <= 0 {
+		grace = defaultStopGrace
+	}
+	if err := terminateProcess(pid); err != nil && !processAlive(pid) {
+		return nil
+	}
+	if waitProcessExit(pid, exited, grace) {
+		return nil
+	}
+	forceKillProcess(pid)
+	if waitProcessExit(pid, exited, killConfirmPeriod) {
+		return nil
+	}
+	return fmt.Errorf("process %d still alive after SIGKILL", pid)
+}
+
+// waitProcessExit 等待进程退出，超时返回 false
+func waitProcessExit(pid int, exited <-chan struct{}, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	if exited != nil {
+		select {
+		case <-exited:
+			return true
+		case <-deadline.C:
+			return false
+		}
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if !processAlive(pid) {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return !processAlive(pid)
+		}
+	}
+}
+
+// CommandContext 与 exec.CommandContext 类似，但子进程在独立的进程组中运行，
+// ctx 结束时强制结束整个进程组，避免命令派生的子进程在超时后继续运行
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return forceKillProcess(cmd.Process.Pid)
+	}
+	cmd.WaitDelay = killConfirmPeriod // 孙进程继承了输出管道时，不让 Wait 一直阻塞
+	return cmd
+}
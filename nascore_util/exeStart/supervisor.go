@@ -0,0 +1,641 @@
+package exeStart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+
+	"go.uber.org/zap"
+)
+
+// ServiceSpec 描述一个由 Supervisor 托管的第三方程序
+type ServiceSpec struct {
+	Name     string   // 服务名，全局唯一，例如 caddy2
+	BinPath  string   // 可执行文件路径
+	Args     []string // 启动参数
+	Env      []string // 追加到当前进程环境变量之后的 KEY=VALUE
+	Unsetenv []string // 不从当前进程继承的环境变量名
+	WorkDir  string   // 工作目录，空表示继承当前目录
+	PidFile  string   // pid 文件完整路径，空表示不写 pid 文件
+
+	Restart RestartPolicy // 退出后的自动重启策略
+	LogFile string        // stdout/stderr 写入的日志文件，空表示只保留内存中的最近日志
+	Log     LogPolicy     // 日志轮转与内存缓存参数
+
+	StopGrace time.Duration // 停止时 SIGTERM 与 SIGKILL 之间的等待时长
+
+	Liveness  Probe // 存活探测，连续失败后按重启策略重启
+	Readiness Probe // 就绪探测，结果通过 Ready 对外暴露
+
+	Limits ResourceLimits // 资源限制，仅 Linux 支持
+
+	PreStart func() // 每次启动进程前执行，例如清理上一次异常退出残留的挂载点
+	PostExit func() // 进程退出后执行，包括 Stop 与异常退出，Stop 会等待其完成
+}
+
+// ServiceState 服务当前所处的状态
+type ServiceState string
+
+const (
+	StateStopped  ServiceState = "stopped"
+	StateStarting ServiceState = "starting"
+	StateRunning  ServiceState = "running"
+	StateStopping ServiceState = "stopping"
+	StateExited   ServiceState = "exited"
+	StateFailed   ServiceState = "failed"
+)
+
+// ServiceStatus 服务状态快照，可直接序列化给 API 使用
+type ServiceStatus struct {
+	Name       string       `json:"name"`
+	State      ServiceState `json:"state"`
+	Pid        int          `json:"pid"`
+	StartedAt  time.Time    `json:"started_at"`
+	ExitedAt   time.Time    `json:"exited_at"`
+	ExitCode   int          `json:"exit_code"`
+	LastError  string       `json:"last_error,omitempty"`
+	StartCount int          `json:"start_count"`
+
+	RestartCount  int       `json:"restart_count"`   // 自动重启累计次数
+	NextRestartAt time.Time `json:"next_restart_at"` // 等待退避时下一次重启的时间
+
+	Healthy        bool   `json:"healthy"` // 存活探测结果，未配置探测时等同于进程在运行
+	Ready          bool   `json:"ready"`   // 就绪探测结果，未配置探测时等同于进程在运行
+	LastProbeError string `json:"last_probe_error,omitempty"`
+
+	LimitWarnings []string `json:"limit_warnings,omitempty"` // 未能应用的资源限制
+}
+
+var (
+	ErrServiceNotFound       = errors.New("service not found")
+	ErrServiceAlreadyRunning = errors.New("service already running")
+)
+
+type service struct {
+	spec   ServiceSpec
+	status ServiceStatus
+	cmd    *exec.Cmd
+	done   chan struct{} // 进程退出后关闭
+	log    *ServiceLog
+
+	restartTimer  *time.Timer   // 等待退避中的重启定时器
+	starting      chan struct{} // 启动过程中不为 nil，启动结束（成功、失败或按 Stop 的请求结束）后关闭
+	stopRequested bool          // 启动过程中收到了 Stop，进程创建后立即结束
+	consecutive   int           // 未稳定运行前的连续重启次数
+	killReason    string        // 非 Stop 触发的结束原因，例如存活探测失败
+}
+
+// Supervisor 统一管理第三方程序的启动、停止、重启与退出码记录
+type Supervisor struct {
+	mu       sync.Mutex
+	services map[string]*service
+	order    []string // 按启动先后排列的服务名，StopAll 逆序停止
+	logger   *zap.SugaredLogger
+}
+
+var (
+	defaultSupervisor     *Supervisor
+	defaultSupervisorOnce sync.Once
+)
+
+// NewSupervisor 创建一个新的 Supervisor
+func NewSupervisor(logger *zap.SugaredLogger) *Supervisor {
+	return &Supervisor{
+		services: make(map[string]*service),
+		logger:   logger,
+	}
+}
+
+// DefaultSupervisor 返回进程内共享的 Supervisor，首次调用时使用传入的 logger 初始化
+func DefaultSupervisor(logger *zap.SugaredLogger) *Supervisor {
+	defaultSupervisorOnce.Do(func() {
+		removeStaleCgroups() // 上次运行异常退出时遗留的 cgroup
+		defaultSupervisor = NewSupervisor(logger)
+	})
+	return defaultSupervisor
+}
+
+// Register 注册或更新服务描述。正在运行的服务在下一次启动时才会使用新的描述，包括日志文件
+func (s *Supervisor) Register(spec ServiceSpec) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if svc, ok := s.services[spec.Name]; ok {
+		svc.spec = spec
+		return
+	}
+	s.services[spec.Name] = &service{
+		spec:   spec,
+		status: ServiceStatus{Name: spec.Name, State: StateStopped},
+		log:    NewServiceLog(spec.LogFile, spec.Log),
+	}
+}
+
+// Start 启动服务。如果 pid 文件中残留了上一次运行的进程，会先将其结束。
+// 手动启动会清空连续重启计数并取消等待中的自动重启
+func (s *Supervisor) Start(name string) error {
+	return s.start(name, false)
+}
+
+func (s *Supervisor) start(name string, auto bool) error {
+	s.mu.Lock()
+	svc, ok := s.services[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceNotFound)
+	}
+	if svc.cmd != nil || svc.status.State == StateStarting { // 进程创建前 cmd 仍为 nil，需同时检查状态，避免并发启动出两个进程
+		s.mu.Unlock()
+		return fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceAlreadyRunning)
+	}
+	if !auto {
+		svc.consecutive = 0
+		svc.cancelRestartLocked()
+	}
+	spec := svc.spec
+	svcLog := svc.log
+	svcLog.reconfigure(spec.LogFile, spec.Log)
+	svc.status.State = StateStarting
+	svc.stopRequested = false
+	started := make(chan struct{})
+	svc.starting = started
+	s.mu.Unlock()
+	defer close(started)
+
+	if spec.PidFile != "" {
+		killByPidFile(spec.PidFile, spec.BinPath, spec.StopGrace, s.logger)
+	}
+	if spec.PreStart != nil {
+		spec.PreStart()
+	}
+
+	stdout, stderr := svcLog.Writer("stdout"), svcLog.Writer("stderr")
+	newCmd := func() *exec.Cmd { // 启动失败的 Cmd 不能再次使用，回退重试时重新创建
+		cmd := exec.Command(spec.BinPath, spec.Args...)
+		cmd.Dir = spec.WorkDir
+		setProcessGroup(cmd)
+		if len(spec.Env) > 0 || len(spec.Unsetenv) > 0 {
+			cmd.Env = append(withoutEnv(os.Environ(), spec.Unsetenv), spec.Env...)
+		}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		cmd.WaitDelay = killConfirmPeriod // 子进程退出后，其派生进程仍占用输出管道时不无限等待
+		return cmd
+	}
+	cmd, limitWarnings, err := startWithLimits(newCmd, spec.Name, spec.Limits)
+	if err != nil {
+		s.mu.Lock()
+		svc.starting = nil
+		svc.status.LastError = err.Error()
+		if svc.stopRequested {
+			svc.status.State = StateStopped
+		} else {
+			svc.status.State = StateFailed
+			if auto {
+				s.scheduleRestartLocked(svc)
+			}
+		}
+		s.mu.Unlock()
+		if spec.PidFile != "" {
+			os.Remove(spec.PidFile)
+		}
+		releaseLimits(spec.Name)
+		s.logger.Warnf("[Supervisor] start %s failed: %v", name, err)
+		return err
+	}
+
+	pid := cmd.Process.Pid
+	for _, w := range limitWarnings {
+		s.logger.Warnf("[Supervisor] %s resource limit not applied: %s", name, w)
+	}
+	if spec.PidFile != "" {
+		if err := WritePidFile(spec.PidFile, pid); err != nil {
+			s.logger.Warnf("[Supervisor] write pid file %s err: %v", spec.PidFile, err)
+		}
+	}
+
+	done := make(chan struct{})
+	s.mu.Lock()
+	svc.cmd = cmd
+	svc.done = done
+	svc.status.State = StateRunning
+	svc.status.Pid = pid
+	svc.status.StartedAt = time.Now()
+	svc.status.LastError = ""
+	svc.status.LastProbeError = ""
+	svc.status.LimitWarnings = limitWarnings
+	svc.status.Healthy = true
+	svc.status.Ready = !spec.Readiness.Enabled()
+	svc.killReason = ""
+	svc.status.StartCount++
+	svc.starting = nil
+	stopRequested := svc.stopRequested
+	if stopRequested {
+		svc.status.State = StateStopping
+	}
+	s.moveToEndLocked(name)
+	s.mu.Unlock()
+
+	go s.wait(svc, cmd, done, stdout, stderr)
+	if stopRequested {
+		s.logger.Debugf("[Supervisor] %s stopped while starting, pid: %d", name, pid)
+		if err := stopProcess(pid, spec.StopGrace, done); err != nil {
+			s.logger.Errorf("[Supervisor] stop %s err: %v", name, err)
+		}
+		return fmt.Errorf("[Supervisor] %s: stopped while starting", name)
+	}
+	if spec.Liveness.Enabled() {
+		go s.runProbe(svc, spec.Liveness, true, done)
+	}
+	if spec.Readiness.Enabled() {
+		go s.runProbe(svc, spec.Readiness, false, done)
+	}
+	s.logger.Debugf("[Supervisor] %s started, pid: %d, pidfile: %s", name, pid, spec.PidFile)
+	eventbus.Publish(eventbus.TypeServiceStarted, "supervisor", map[string]any{"service": name, "pid": pid, "auto_restart": auto})
+	return nil
+}
+
+// wait 等待进程退出并记录退出码
+func (s *Supervisor) wait(svc *service, cmd *exec.Cmd, done chan struct{}, outputs ...*LineWriter) {
+	err := cmd.Wait()
+	for _, w := range outputs {
+		w.Flush()
+	}
+	s.mu.Lock()
+	name := svc.spec.Name
+	s.mu.Unlock()
+	releaseLimits(name) // 在下一次启动之前删除 cgroup
+	exitCode := 0
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	s.mu.Lock()
+	stopping := svc.status.State == StateStopping
+	killReason := svc.killReason
+	svc.cmd = nil
+	svc.done = nil
+	svc.status.Pid = 0
+	svc.status.Healthy = false
+	svc.status.Ready = false
+	svc.status.ExitedAt = time.Now()
+	svc.status.ExitCode = exitCode
+	switch {
+	case stopping:
+		svc.status.State = StateStopped
+	case killReason != "":
+		svc.status.State = StateFailed
+		svc.status.LastError = killReason
+	case err != nil:
+		svc.status.State = StateFailed
+		svc.status.LastError = err.Error()
+	default:
+		svc.status.State = StateExited
+	}
+	pidFile := svc.spec.PidFile
+	postExit := svc.spec.PostExit
+	failed := err != nil || exitCode != 0 || killReason != ""
+	if !stopping && svc.spec.Restart.shouldRestart(failed) {
+		if svc.status.ExitedAt.Sub(svc.status.StartedAt) >= restartResetAfter {
+			svc.consecutive = 0
+		}
+		s.scheduleRestartLocked(svc)
+	}
+	s.mu.Unlock()
+
+	if pidFile != "" {
+		os.Remove(pidFile)
+	}
+	if postExit != nil {
+		postExit()
+	}
+	close(done)
+	s.logger.Debugf("[Supervisor] %s exited, code: %d, err: %v", name, exitCode, err)
+	data := map[string]any{"service": name, "exit_code": exitCode, "failed": failed && !stopping, "stopped": stopping}
+	if killReason != "" {
+		data["error"] = killReason
+	} else if err != nil {
+		data["error"] = err.Error()
+	}
+	eventbus.Publish(eventbus.TypeServiceExited, "supervisor", data)
+}
+
+// scheduleRestartLocked 按退避时间安排一次自动重启，超过重启次数上限时放弃。调用方需持有 s.mu
+func (s *Supervisor) scheduleRestartLocked(svc *service) {
+	name := svc.spec.Name
+	policy := svc.spec.Restart
+	svc.consecutive++
+	if policy.MaxRestarts > 0 && svc.consecutive > policy.MaxRestarts {
+		svc.status.State = StateFailed
+		svc.status.LastError = fmt.Sprintf("gave up after %d consecutive restarts", policy.MaxRestarts)
+		svc.status.NextRestartAt = time.Time{}
+		s.logger.Errorf("[Supervisor] %s keeps crashing, gave up after %d consecutive restarts", name, policy.MaxRestarts)
+		eventbus.Publish(eventbus.TypeServiceGaveUp, "supervisor", map[string]any{"service": name, "restarts": policy.MaxRestarts, "error": svc.status.LastError})
+		return
+	}
+	delay := policy.backoff(svc.consecutive)
+	svc.status.NextRestartAt = time.Now().Add(delay)
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		if svc.restartTimer != timer { // 已被 Stop 或手动 Start 取消
+			s.mu.Unlock()
+			return
+		}
+		svc.restartTimer = nil
+		svc.status.NextRestartAt = time.Time{}
+		svc.status.RestartCount++
+		attempt := svc.consecutive
+		s.mu.Unlock()
+		s.logger.Warnf("[Supervisor] restarting %s, attempt %d", name, attempt)
+		if err := s.start(name, true); err != nil {
+			s.logger.Warnf("[Supervisor] restart %s failed: %v", name, err)
+		}
+	})
+	svc.restartTimer = timer
+	s.logger.Warnf("[Supervisor] %s exited unexpectedly, restart in %s", name, delay.Round(time.Millisecond))
+}
+
+// cancelRestartLocked 取消等待中的自动重启。调用方需持有 s.mu
+func (svc *service) cancelRestartLocked() {
+	if svc.restartTimer != nil {
+		svc.restartTimer.Stop()
+		svc.restartTimer = nil
+	}
+	svc.status.NextRestartAt = time.Time{}
+}
+
+// Stop 停止服务并等待进程退出。服务不是由本进程启动时，按 pid 文件结束残留进程
+func (s *Supervisor) Stop(name string) error {
+	s.mu.Lock()
+	svc, ok := s.services[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceNotFound)
+	}
+	svc.cancelRestartLocked()
+	if starting := svc.starting; starting != nil { // 正在启动：请求启动方在进程创建后立即结束，等待其完成
+		svc.stopRequested = true
+		s.mu.Unlock()
+		<-starting
+		return s.Stop(name)
+	}
+	cmd := svc.cmd
+	done := svc.done
+	pidFile := svc.spec.PidFile
+	binPath := svc.spec.BinPath
+	grace := svc.spec.StopGrace
+	if cmd == nil {
+		svc.status.State = StateStopped
+		s.mu.Unlock()
+		if pidFile != "" {
+			killByPidFile(pidFile, binPath, grace, s.logger)
+		}
+		return nil
+	}
+	svc.status.State = StateStopping
+	s.mu.Unlock()
+
+	if err := stopProcess(cmd.Process.Pid, grace, done); err != nil {
+		s.logger.Errorf("[Supervisor] stop %s err: %v", name, err)
+		return err
+	}
+	s.logger.Debugf("[Supervisor] %s stopped", name)
+	return nil
+}
+
+// Unregister 停止并移除服务
+func (s *Supervisor) Unregister(name string) error {
+	if err := s.Stop(name); err != nil {
+		if errors.Is(err, ErrServiceNotFound) {
+			return nil
+		}
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[name]
+	if !ok {
+		return nil
+	}
+	svc.cancelRestartLocked()
+	svc.log.Close()
+	delete(s.services, name)
+	for i, n := range s.order {
+		if n == name {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Wait 等待服务当前的进程退出并返回此时的状态，服务未在运行时立即返回。ctx 结束时返回 ctx.Err()
+func (s *Supervisor) Wait(ctx context.Context, name string) (ServiceStatus, error) {
+	s.mu.Lock()
+	svc, ok := s.services[name]
+	if !ok {
+		s.mu.Unlock()
+		return ServiceStatus{}, fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceNotFound)
+	}
+	done := svc.done
+	s.mu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			status, _ := s.Status(name)
+			return status, ctx.Err()
+		}
+	}
+	return s.Status(name)
+}
+
+// StopAll 按启动顺序的逆序停止所有服务
+func (s *Supervisor) StopAll() {
+	s.mu.Lock()
+	order := make([]string, len(s.order))
+	copy(order, s.order)
+	s.mu.Unlock()
+	for i := len(order) - 1; i >= 0; i-- {
+		if err := s.Stop(order[i]); err != nil {
+			s.logger.Warnf("[Supervisor] StopAll %s err: %v", order[i], err)
+		}
+	}
+}
+
+// moveToEndLocked 将服务移动到启动顺序的末尾。调用方需持有 s.mu
+func (s *Supervisor) moveToEndLocked(name string) {
+	for i, n := range s.order {
+		if n == name {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	s.order = append(s.order, name)
+}
+
+// Restart 停止后重新启动服务
+func (s *Supervisor) Restart(name string) error {
+	if err := s.Stop(name); err != nil {
+		return err
+	}
+	return s.Start(name)
+}
+
+// Status 返回单个服务的状态快照
+func (s *Supervisor) Status(name string) (ServiceStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[name]
+	if !ok {
+		return ServiceStatus{}, fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceNotFound)
+	}
+	return svc.status, nil
+}
+
+// List 返回所有已注册服务的状态快照，按名称排序
+func (s *Supervisor) List() []ServiceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]ServiceStatus, 0, len(s.services))
+	for _, svc := range s.services {
+		list = append(list, svc.status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Tail 返回服务最近的 n 行输出
+func (s *Supervisor) Tail(name string, n int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[name]
+	if !ok {
+		return nil, fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceNotFound)
+	}
+	return svc.log.Tail(n), nil
+}
+
+// SubscribeLog 返回服务最近的 n 行输出，并订阅之后的新输出，调用返回的函数取消订阅
+func (s *Supervisor) SubscribeLog(name string, n int) ([]string, <-chan string, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[name]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("[Supervisor] %s: %w", name, ErrServiceNotFound)
+	}
+	ch, cancel := svc.log.Subscribe()
+	return svc.log.Tail(n), ch, cancel, nil
+}
+
+// Ready 返回服务是否就绪。managed 为 false 表示服务未由 Supervisor 托管，调用方应按原有逻辑处理
+func (s *Supervisor) Ready(name string) (ready bool, managed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[name]
+	if !ok || svc.status.StartCount == 0 {
+		return false, false
+	}
+	return svc.status.Ready, true
+}
+
+// runProbe 在进程运行期间周期性执行探测，进程退出（done 关闭）后结束。
+// 存活探测连续失败达到阈值时结束进程，由重启策略决定是否重启
+func (s *Supervisor) runProbe(svc *service, probe Probe, liveness bool, done <-chan struct{}) {
+	kind := "readiness"
+	if liveness {
+		kind = "liveness"
+	}
+	name := svc.spec.Name
+	if probe.InitialDelay > 0 {
+		select {
+		case <-done:
+			return
+		case <-time.After(probe.InitialDelay):
+		}
+	}
+	ticker := time.NewTicker(probe.Interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := probe.Check(ctx)
+		cancel()
+
+		s.mu.Lock()
+		if svc.done != done { // 进程已退出或已被重新启动
+			s.mu.Unlock()
+			return
+		}
+		if err == nil {
+			failures = 0
+			if liveness {
+				svc.status.Healthy = true
+			} else {
+				svc.status.Ready = true
+			}
+		} else {
+			failures++
+			svc.status.LastProbeError = kind + ": " + err.Error()
+			if failures >= probe.FailureThreshold {
+				if liveness {
+					svc.status.Healthy = false
+				} else {
+					svc.status.Ready = false
+				}
+			}
+		}
+		pid := svc.status.Pid
+		grace := svc.spec.StopGrace
+		unhealthy := liveness && failures >= probe.FailureThreshold
+		if unhealthy {
+			svc.killReason = fmt.Sprintf("liveness probe failed %d times: %v", failures, err)
+		}
+		s.mu.Unlock()
+
+		if unhealthy {
+			s.logger.Warnf("[Supervisor] %s liveness probe failed %d times, killing pid %d: %v", name, failures, pid, err)
+			eventbus.Publish(eventbus.TypeServiceUnhealthy, "supervisor", map[string]any{"service": name, "pid": pid, "failures": failures, "error": err.Error()})
+			if err := stopProcess(pid, grace, done); err != nil {
+				s.logger.Errorf("[Supervisor] kill unhealthy %s err: %v", name, err)
+			}
+			return
+		}
+		if err != nil {
+			s.logger.Debugf("[Supervisor] %s %s probe failed (%d/%d): %v", name, kind, failures, probe.FailureThreshold, err)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// withoutEnv 去掉 env 中名称在 names 里的 KEY=VALUE
+func withoutEnv(env []string, names []string) []string {
+	if len(names) == 0 {
+		return env
+	}
+	return slices.DeleteFunc(env, func(kv string) bool {
+		name, _, _ := strings.Cut(kv, "=")
+		return slices.Contains(names, name)
+	})
+}
@@ -2,8 +2,6 @@ package followStartAndCron
 
 import (
 	"context"
-	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nas-core/nascore/nascore_util/exeStart"
 	"github.com/nas-core/nascore/nascore_util/isDevMode"
 	"github.com/nas-core/nascore/nascore_util/system_config"
 	"go.uber.org/zap"
@@ -18,8 +17,9 @@ import (
 
 // Nascore_extended_followStart 扩展的启动跟踪函数
 func Nascore_extended_followStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (err error) {
-	if len(nsCfg.Server.TempFilePath) > 0 && nsCfg.Server.TempFilePath[len(nsCfg.Server.TempFilePath)-1] != '/' {
-		nsCfg.Server.TempFilePath += "/"
+	tempFilePath := nsCfg.Server.TempFilePath // 配置是共享的只读快照，不能就地修改
+	if len(tempFilePath) > 0 && tempFilePath[len(tempFilePath)-1] != '/' {
+		tempFilePath += "/"
 	}
 
 	var searchPaths []string
@@ -93,7 +93,7 @@ func Nascore_extended_followStart(nsCfg *system_config.SysCfg, logger *zap.Sugar
 
 			switch {
 			case strings.Contains(strings.ToLower(fileName), "tv"), strings.Contains(strings.ToLower(fileName), "vod"):
-				cmdParams := []string{"-s", nsCfg.Server.TempFilePath + system_config.ExtensionSocketMap["nascore_vod"], "-githubDownloadMirror", nsCfg.ThirdPartyExt.GitHubDownloadMirror}
+				cmdParams := []string{"-s", tempFilePath + system_config.ExtensionSocketMap["nascore_vod"], "-githubDownloadMirror", nsCfg.ThirdPartyExt.GitHubDownloadMirror}
 				logger.Debug("[nascore] 🔹Starting execution: %s, parameters: %v", filePath, cmdParams)
 				executeIfMatching(filePath, fileName, cmdParams, logger)
 			default:
@@ -140,23 +140,9 @@ func CheckAllExtensionStatusOnce(nsCfg *system_config.SysCfg) {
 		}
 		socketPath += socketFile
 
-		client := &http.Client{
-			Transport: &http.Transport{
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return net.Dial("unix", socketPath)
-				},
-			},
-			Timeout: 2 * time.Second,
-		}
-		req, _ := http.NewRequest("GET", "http://unix/ping", nil)
-		resp, err := client.Do(req)
-		if err == nil && resp.StatusCode == 200 {
-			system_config.ExtensionStatusMap[extName] = true
-		} else {
-			system_config.ExtensionStatusMap[extName] = false
-		}
-		if resp != nil {
-			resp.Body.Close()
-		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := exeStart.PingUnixSocket(ctx, socketPath)
+		cancel()
+		system_config.ExtensionStatusMap[extName] = err == nil
 	}
 }
@@ -0,0 +1,215 @@
+package followStartAndCron
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/cmdline"
+	"github.com/nas-core/nascore/nascore_util/exeStart"
+	"github.com/nas-core/nascore/nascore_util/secretmask"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	serviceGroupRclone = "rclone"
+	serviceGroupLego   = "lego"
+
+	unmountTimeout = 30 * time.Second
+)
+
+// serviceGroup 把命令块中以 &nascore 结尾的命令注册为托管服务，服务名为 <prefix>-<挂载点目录名或序号>
+type serviceGroup struct {
+	prefix    string
+	nsCfg     *system_config.SysCfg
+	supervise system_config.SuperviseStru
+	hooks     func(c cmdline.Command) (preStart, postExit func()) // 可为 nil
+}
+
+var (
+	groupServicesMu sync.Mutex
+	groupServices   = make(map[string][]string) // prefix -> 上一次执行命令块时注册的服务名
+)
+
+func rcloneServiceGroup(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) *serviceGroup {
+	return &serviceGroup{
+		prefix:    serviceGroupRclone,
+		nsCfg:     nsCfg,
+		supervise: nsCfg.ThirdPartyExt.Rclone.Supervise,
+		hooks: func(c cmdline.Command) (func(), func()) {
+			mountPoint := rcloneMountPoint(c.Args)
+			if mountPoint == "" {
+				return nil, nil
+			}
+			unmount := func() { unmountRclone(nsCfg, logger, mountPoint) }
+			return unmount, unmount
+		},
+	}
+}
+
+// legoServiceGroup lego 的 run、renew 都是运行一次就正常退出的命令，always 按 on-failure 处理，只在失败时重试
+func legoServiceGroup(nsCfg *system_config.SysCfg) *serviceGroup {
+	supervise := nsCfg.ThirdPartyExt.AcmeLego.Supervise
+	if exeStart.RestartPolicyFromCfg(supervise).Mode == exeStart.RestartAlways {
+		supervise.RestartPolicy = string(exeStart.RestartOnFailure)
+	}
+	return &serviceGroup{prefix: serviceGroupLego, nsCfg: nsCfg, supervise: supervise}
+}
+
+// serviceName rclone 挂载以挂载点目录名命名，其它命令按后台命令的序号命名
+func (g *serviceGroup) serviceName(c cmdline.Command, index int, used map[string]bool) string {
+	suffix := strconv.Itoa(index)
+	if mountPoint := rcloneMountPoint(c.Args); mountPoint != "" {
+		suffix = sanitizeServiceName(filepath.Base(mountPoint))
+	}
+	name := g.prefix + "-" + suffix
+	if used[name] {
+		name += "-" + strconv.Itoa(index)
+	}
+	used[name] = true
+	return name
+}
+
+func sanitizeServiceName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// start 按最新的命令注册并（重新）启动服务
+func (g *serviceGroup) start(c cmdline.Command, name string, logger *zap.SugaredLogger) error {
+	spec := exeStart.CommandServiceSpec(g.nsCfg, name, c.Args, c.Env, g.supervise)
+	spec.Unsetenv = c.Unset
+	if g.hooks != nil {
+		spec.PreStart, spec.PostExit = g.hooks(c)
+	}
+	return exeStart.StartSpec(spec, logger)
+}
+
+// prune 停止并移除上一次执行时注册、但已不在命令块中的服务
+func (g *serviceGroup) prune(current []string, logger *zap.SugaredLogger) {
+	groupServicesMu.Lock()
+	previous := groupServices[g.prefix]
+	groupServices[g.prefix] = current
+	groupServicesMu.Unlock()
+	keep := make(map[string]bool, len(current))
+	for _, name := range current {
+		keep[name] = true
+	}
+	sv := exeStart.DefaultSupervisor(logger)
+	for _, name := range previous {
+		if keep[name] {
+			continue
+		}
+		logger.Debugf("[command] %s removed from command block, stopping", name)
+		if err := sv.Unregister(name); err != nil {
+			logger.Warnf("[command] stop %s err: %v", name, err)
+		}
+	}
+}
+
+// rcloneMountPoint 从 rclone mount <remote> <mountpoint> 中取出挂载点，不是挂载命令时返回空
+func rcloneMountPoint(args []string) string {
+	if len(args) == 0 || !strings.Contains(strings.ToLower(filepath.Base(args[0])), "rclone") {
+		return ""
+	}
+	var positional []string
+	seenMount := false
+	for _, a := range args[1:] {
+		if !seenMount {
+			seenMount = a == "mount" || a == "nfsmount"
+			continue
+		}
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		positional = append(positional, a)
+		if len(positional) == 2 {
+			return positional[1]
+		}
+	}
+	return ""
+}
+
+// rcloneBatchUnmount 大于 0 时托管的挂载进程退出后不单独执行 AutoUnMountCommand 中对应的命令，由 stopRcloneThenUnmount 随后统一执行一次
+var rcloneBatchUnmount atomic.Int32
+
+// stopRcloneThenUnmount 先用 stop 停止托管的挂载服务（否则卸载后会被守护重新挂载），再执行一次 AutoUnMountCommand
+func stopRcloneThenUnmount(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, stop func()) {
+	rcloneBatchUnmount.Add(1)
+	stop()
+	rcloneBatchUnmount.Add(-1)
+	exeRcloneAutoUnMount(ctx, nsCfg, logger)
+}
+
+// unmountRclone 卸载挂载点。优先执行 AutoUnMountCommand 中包含该挂载点的命令，
+// 否则在 Linux 下挂载点仍处于挂载状态时依次尝试 fusermount3、fusermount 与 umount
+func unmountRclone(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, mountPoint string) {
+	nsCfg = system_config.CurrentOr(nsCfg) // 卸载时按最新配置中的 AutoUnMountCommand
+	ctx, cancel := context.WithTimeout(context.Background(), unmountTimeout)
+	defer cancel()
+	if c, ok := matchingUnmountCommand(nsCfg, mountPoint); ok {
+		if rcloneBatchUnmount.Load() > 0 {
+			return
+		}
+		cmd := exeStart.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+		cmd.Env = c.Environ(cmd.Environ())
+		out, err := cmd.CombinedOutput()
+		logger.Debugf("[rclone] unmount %s: %s, output: %s, err: %v", mountPoint, secretmask.String(c.Raw), secretmask.String(string(out)), secretmask.Error(err))
+		return
+	}
+	if runtime.GOOS != "linux" || !isMountReady(mountPoint) {
+		return
+	}
+	for _, argv := range [][]string{{"fusermount3", "-u", mountPoint}, {"fusermount", "-u", mountPoint}, {"umount", "-l", mountPoint}} {
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		out, err := exeStart.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput()
+		if err == nil {
+			logger.Debugf("[rclone] unmounted %s with %s", mountPoint, argv[0])
+			return
+		}
+		logger.Warnf("[rclone] %s %s err: %v, output: %s", strings.Join(argv, " "), mountPoint, err, out)
+	}
+}
+
+// matchingUnmountCommand 在 AutoUnMountCommand 中查找参数包含该挂载点的命令
+func matchingUnmountCommand(nsCfg *system_config.SysCfg, mountPoint string) (cmdline.Command, bool) {
+	commands, err := cmdline.Parse(nsCfg.ThirdPartyExt.Rclone.AutoUnMountCommand, cmdline.Options{Vars: rcloneCommandVars(nsCfg), KeepBackslash: runtime.GOOS == "windows"})
+	if err != nil {
+		return cmdline.Command{}, false
+	}
+	target := filepath.Clean(mountPoint)
+	for _, c := range commands {
+		if c.Assign {
+			continue
+		}
+		for _, a := range c.Args[1:] {
+			if filepath.Clean(a) == target {
+				return c, true
+			}
+		}
+	}
+	return cmdline.Command{}, false
+}
+
+// serviceOutput 服务最近的输出，用于命令结果
+func serviceOutput(logger *zap.SugaredLogger, name string) string {
+	lines, err := exeStart.DefaultSupervisor(logger).Tail(name, 200)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
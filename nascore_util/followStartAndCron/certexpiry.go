@@ -0,0 +1,90 @@
+package followStartAndCron
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+const JobCertExpiry = "cert-expiry"
+
+func init() {
+	scheduler.register(FuncJob{
+		JobName: JobCertExpiry,
+		Spec: func(nsCfg *system_config.SysCfg) string {
+			if nsCfg.Notify.CertExpiryWarnDays <= 0 || nsCfg.ThirdPartyExt.AcmeLego.LEGO_PATH == "" {
+				return ""
+			}
+			return nsCfg.Notify.CertCheckCron
+		},
+		Fn: checkCertExpiry,
+	}, true)
+}
+
+// checkCertExpiry 检查 lego 申请的证书（LEGO_PATH/certificates/*.crt），剩余有效期不足时发布 cert.expiring 事件
+func checkCertExpiry(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+	dir := filepath.Join(nsCfg.ThirdPartyExt.AcmeLego.LEGO_PATH, "certificates")
+	files, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+	if err != nil {
+		return "", err
+	}
+	warnBefore := time.Duration(nsCfg.Notify.CertExpiryWarnDays) * 24 * time.Hour
+	now := time.Now()
+	var lines []string
+	for _, file := range files {
+		if strings.HasSuffix(file, ".issuer.crt") {
+			continue
+		}
+		cert, err := readLeafCert(file)
+		if err != nil {
+			logger.Warnf("[cert] read %s err: %v", file, err)
+			lines = append(lines, fmt.Sprintf("%s: %v", filepath.Base(file), err))
+			continue
+		}
+		domain := strings.TrimSuffix(filepath.Base(file), ".crt")
+		daysLeft := int(cert.NotAfter.Sub(now).Hours() / 24)
+		lines = append(lines, fmt.Sprintf("%s: expires %s (%d days left)", domain, cert.NotAfter.Format(time.RFC3339), daysLeft))
+		if cert.NotAfter.Sub(now) < warnBefore {
+			logger.Warnf("[cert] certificate for %s expires at %s", domain, cert.NotAfter.Format(time.RFC3339))
+			eventbus.Publish(eventbus.TypeCertExpiring, "cert", map[string]any{
+				"domain":     domain,
+				"expires_at": cert.NotAfter,
+				"days_left":  daysLeft,
+				"file":       file,
+			})
+		}
+	}
+	if len(lines) == 0 {
+		return "no certificates found in " + dir, nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// readLeafCert 读取 PEM 文件中的第一张证书
+func readLeafCert(file string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+	return nil, fmt.Errorf("no certificate found")
+}
@@ -0,0 +1,268 @@
+package followStartAndCron
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/cmdline"
+	"github.com/nas-core/nascore/nascore_util/exeStart"
+	"github.com/nas-core/nascore/nascore_util/secretmask"
+
+	"go.uber.org/zap"
+)
+
+// CommandResult 命令块中一行命令的执行结果
+type CommandResult struct {
+	Line       int       `json:"line"`
+	Command    string    `json:"command"` // 原始文本，密钥显示为 ***
+	Args       []string  `json:"args"`
+	Background bool      `json:"background"`
+	Service    string    `json:"service,omitempty"` // 后台命令作为托管服务运行时的服务名
+	Pid        int       `json:"pid,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"` // 后台命令仍在运行时为零值
+	ExitCode   int       `json:"exit_code"`   // 未能启动或被信号结束时为 -1
+	Stdout     string    `json:"stdout"`
+	Stderr     string    `json:"stderr"`
+	Err        error     `json:"-"`
+}
+
+// Done 命令是否已结束
+func (r CommandResult) Done() bool {
+	return !r.FinishedAt.IsZero()
+}
+
+// CommandRun 一次命令块的执行。返回时顺序命令已全部结束，后台命令通过 Wait 或 WaitContext 等待
+type CommandRun struct {
+	mu      sync.Mutex
+	results []*CommandResult // 按行顺序
+	errs    []error          // 解析错误或 ctx 结束导致跳过剩余行
+	wg      sync.WaitGroup
+}
+
+// Wait 等待所有后台命令结束并返回全部结果
+func (r *CommandRun) Wait() []CommandResult {
+	r.wg.Wait()
+	return r.Results()
+}
+
+// WaitContext 等待后台命令结束，ctx 结束时不再等待，返回此时的结果
+func (r *CommandRun) WaitContext(ctx context.Context) []CommandResult {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return r.Results()
+}
+
+// Results 返回当前的结果快照，仍在运行的后台命令只有开始时间
+func (r *CommandRun) Results() []CommandResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]CommandResult, len(r.results))
+	for i, res := range r.results {
+		results[i] = *res
+	}
+	return results
+}
+
+// Err 合并已结束命令的错误
+func (r *CommandRun) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := append([]error(nil), r.errs...)
+	for _, res := range r.results {
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Output 把各行命令的输出合并为一段文本，用于保存到任务运行记录
+func (r *CommandRun) Output() string {
+	var b strings.Builder
+	for _, res := range r.Results() {
+		b.WriteString(formatCommandOutput(res.Command, res.Stdout, res.Stderr))
+	}
+	return b.String()
+}
+
+func (r *CommandRun) finish(res *CommandResult, cmd *exec.Cmd, stdout, stderr *bytes.Buffer, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res.FinishedAt = time.Now()
+	res.ExitCode = -1
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	res.Stdout = secretmask.String(stdout.String())
+	res.Stderr = secretmask.String(stderr.String())
+	res.Err = secretmask.Error(err)
+}
+
+// finishService 托管服务的进程退出后记录结果，输出取自服务日志
+func (r *CommandRun) finishService(res *CommandResult, status exeStart.ServiceStatus, output string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res.FinishedAt = time.Now()
+	res.ExitCode = status.ExitCode
+	res.Stdout = secretmask.String(output)
+	res.Err = secretmask.Error(err)
+	if err == nil && status.LastError != "" && status.State == exeStart.StateFailed {
+		res.Err = fmt.Errorf("%s: %s", res.Service, secretmask.String(status.LastError))
+	}
+}
+
+// excMultiLineCommand_Sequentially 解析并逐行执行命令块，语法见 cmdline 包，vars 为可用的占位符。
+// 命令块有语法错误时一行也不执行。顺序执行的命令受 ctx 控制，ctx 结束时结束其整个进程组并跳过剩余的行；
+// 以 &nascore 结尾的后台命令不受 ctx 影响，返回时可能仍在运行。group 不为空时后台命令注册为托管服务，
+// 有 pid、日志与重启策略，上一次注册而本次已不存在的服务会被停止。
+// 名称匹配 SecretEnvPatterns 的环境变量的值登记到 secretmask，结果、日志文件与调试日志中显示为 ***
+func excMultiLineCommand_Sequentially(ctx context.Context, commandStr *string, vars map[string]string, group *serviceGroup, logger *zap.SugaredLogger, logFile string) *CommandRun {
+	run := &CommandRun{}
+	commands, err := parseCommandBlock(*commandStr, vars)
+	if err != nil {
+		logger.Errorf("[command] parse command block err: %v", err)
+		run.errs = append(run.errs, err)
+		return run
+	}
+	for _, c := range commands {
+		secretmask.Default().AddEnv(c.Env) // 先登记整个命令块的密钥，输出中出现的任何一个都能被替换
+	}
+	var services []string
+	usedNames := make(map[string]bool)
+	background := 0
+	completed := true
+	for _, c := range commands {
+		if err := ctx.Err(); err != nil {
+			run.mu.Lock()
+			run.errs = append(run.errs, err)
+			run.mu.Unlock()
+			completed = false
+			break
+		}
+		if c.Assign {
+			continue
+		}
+		if len(c.Unknown) > 0 {
+			logger.Warnf("[command] line %d: undefined variables %v expanded to empty", c.Line, c.Unknown)
+		}
+		line := secretmask.String(c.Raw)
+		if c.Background {
+			background++
+		}
+		if c.Background && group != nil {
+			name := group.serviceName(c, background, usedNames)
+			services = append(services, name)
+			run.startService(group, c, name, logger, logFile)
+			continue
+		}
+		var cmd *exec.Cmd
+		if c.Background {
+			cmd = exec.Command(c.Args[0], c.Args[1:]...)
+		} else {
+			cmd = exeStart.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+		}
+		cmd.Env = c.Environ(os.Environ()) // 继承当前环境，移除 unset 的变量并添加新的环境变量
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		cmd.Stdin = &bytes.Buffer{}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		res := &CommandResult{Line: c.Line, Command: line, Args: secretmask.Strings(c.Args), Background: c.Background, StartedAt: time.Now()}
+		run.mu.Lock()
+		run.results = append(run.results, res)
+		run.mu.Unlock()
+		if c.Background { // 以 &nascore 结尾
+			logger.Debug("MultiLineCommand Asynchronous execution   ", line)
+			run.wg.Add(1)
+			go func() { // 使用 goroutine 执行命令
+				defer run.wg.Done()
+				err := cmd.Run()
+				run.finish(res, cmd, stdout, stderr, err)
+				writeLogToFile(logFile, line, res.Stdout, res.Stderr)
+			}()
+		} else {
+			logger.Debug("MultiLineCommand Sequential execution   ", line)
+
+			err := cmd.Run() // 顺序执行命令
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = fmt.Errorf("%s: %w", line, ctxErr) // 被超时结束的命令返回的是 signal: killed，这里换成超时原因
+			}
+			run.finish(res, cmd, stdout, stderr, err)
+			writeLogToFile(logFile, line, res.Stdout, res.Stderr)
+		}
+	}
+	if group != nil && completed {
+		group.prune(services, logger)
+	}
+	return run
+}
+
+// parseCommandBlock 按执行时的选项解析命令块，Windows 下反斜杠按字面保留
+func parseCommandBlock(script string, vars map[string]string) ([]cmdline.Command, error) {
+	return cmdline.Parse(script, cmdline.Options{Vars: vars, KeepBackslash: runtime.GOOS == "windows"})
+}
+
+// startService 以托管服务运行后台命令，在后台等待服务的进程退出后记录结果
+func (r *CommandRun) startService(group *serviceGroup, c cmdline.Command, name string, logger *zap.SugaredLogger, logFile string) {
+	line := secretmask.String(c.Raw)
+	res := &CommandResult{Line: c.Line, Command: line, Args: secretmask.Strings(c.Args), Background: true, Service: name, StartedAt: time.Now()}
+	r.mu.Lock()
+	r.results = append(r.results, res)
+	r.mu.Unlock()
+	logger.Debugf("MultiLineCommand start service %s   %s", name, line)
+	if err := group.start(c, name, logger); err != nil {
+		r.finishService(res, exeStart.ServiceStatus{ExitCode: -1}, "", err)
+		return
+	}
+	sv := exeStart.DefaultSupervisor(logger)
+	if status, err := sv.Status(name); err == nil {
+		r.mu.Lock()
+		res.Pid = status.Pid
+		r.mu.Unlock()
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		status, err := sv.Wait(context.Background(), name)
+		r.finishService(res, status, serviceOutput(logger, name), err)
+		writeLogToFile(logFile, line, res.Stdout, "")
+	}()
+}
+
+func formatCommandOutput(cmdLine, outStr, errStr string) string {
+	var b strings.Builder
+	b.WriteString("\n[cmd] " + cmdLine + "\n")
+	if outStr != "" {
+		b.WriteString("[stdout]\n" + outStr + "\n")
+	}
+	if errStr != "" {
+		b.WriteString("[stderr]\n" + errStr + "\n")
+	}
+	return b.String()
+}
+
+// writeLogToFile 将命令执行的输出写入到指定日志文件，写入前替换已知的密钥
+func writeLogToFile(logFile, cmdLine, outStr, errStr string) {
+	if logFile != "" {
+		f, ferr := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if ferr == nil {
+			defer f.Close()
+			f.WriteString(secretmask.String(formatCommandOutput(cmdLine, outStr, errStr)))
+		}
+	}
+}
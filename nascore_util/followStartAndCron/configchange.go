@@ -0,0 +1,191 @@
+package followStartAndCron
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+	"github.com/nas-core/nascore/nascore_util/exeStart"
+	"github.com/nas-core/nascore/nascore_util/notify"
+	"github.com/nas-core/nascore/nascore_util/secretmask"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// ConfigChangeFunc 配置重载后调用，diff 为新旧配置的全部差异
+type ConfigChangeFunc func(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger)
+
+type configChangeHandler struct {
+	name     string
+	sections []string // 其中任一路径本身或其下的字段变化时调用
+	apply    ConfigChangeFunc
+}
+
+type configChangeSet struct {
+	old, new *system_config.SysCfg
+	diff     system_config.ConfigDiff
+	logger   *zap.SugaredLogger
+}
+
+// 这些字段在启动时用于监听端口与注册路由，修改后需要重启 nascore
+var restartRequiredPaths = []string{
+	"Server.httpPort",
+	"Server.HttpsEnable",
+	"Server.httpsPort",
+	"Server.tlscert",
+	"Server.tlskey",
+	"Server.IsRunInServerLess",
+	"Server.PrefixWebUI",
+	"Server.WebuiAndApiEnable",
+	"Server.ApiEnable",
+	"Server.WebDavEnable",
+	"Server.DefaultStaticFileService",
+	"Server.DefaultStaticFileServiceEnable",
+}
+
+// 影响所有托管服务 pid 文件与日志位置的字段
+var serviceCommonPaths = []string{"Server.TempFilePath", "ThirdPartyExt.ServiceLogDir"}
+
+var (
+	configChangeMu       sync.Mutex
+	configChangeHandlers = []configChangeHandler{
+		{name: "server", sections: restartRequiredPaths, apply: applyServerChange},
+		{name: "events", sections: []string{"ThirdPartyExt.SecretEnvPatterns", "Events", "Notify", "Server.TempFilePath"}, apply: applyEventConfig},
+		{name: unitRclone, sections: append([]string{"ThirdPartyExt.Rclone"}, serviceCommonPaths...), apply: applyRcloneChange},
+		serviceChangeHandler(exeStart.ServiceDDNSGo, "ThirdPartyExt.DdnsGO", &isDdnsSGOFollowStart, exeStart.DDNSGoSpec,
+			func(c *system_config.SysCfg) bool { return c.ThirdPartyExt.DdnsGO.AutoStartEnable }),
+		serviceChangeHandler(exeStart.ServiceCaddy2, "ThirdPartyExt.Caddy2", &isCaddy2FollowStart, exeStart.Caddy2Spec,
+			func(c *system_config.SysCfg) bool { return c.ThirdPartyExt.Caddy2.AutoStartEnable }),
+		serviceChangeHandler(exeStart.ServiceOpenlist, "ThirdPartyExt.Openlist", &isOpenlistFollowStart, exeStart.OpenlistSpec,
+			func(c *system_config.SysCfg) bool { return c.ThirdPartyExt.Openlist.AutoStartEnable }),
+		// 放在各服务的处理函数之后，停止、重启时注册的旧描述最终都被新配置覆盖
+		{name: "services", sections: append([]string{"ThirdPartyExt.DdnsGO", "ThirdPartyExt.Caddy2", "ThirdPartyExt.Openlist"}, serviceCommonPaths...), apply: applyServicesRegistration},
+	}
+
+	configChanges     = make(chan configChangeSet, 16)
+	configChangesOnce sync.Once
+)
+
+// RegisterConfigChangeHandler 注册配置变化的处理函数，sections 为 TOML 路径前缀，例如 ThirdPartyExt.AdGuard。
+// 处理函数按注册顺序在同一个 goroutine 中依次执行，可以阻塞，但会推迟后续重载的处理
+func RegisterConfigChangeHandler(name string, fn ConfigChangeFunc, sections ...string) {
+	configChangeMu.Lock()
+	defer configChangeMu.Unlock()
+	configChangeHandlers = append(configChangeHandlers, configChangeHandler{name: name, sections: sections, apply: fn})
+}
+
+// applyConfigChanges 把一次重载的差异交给处理函数。停止、重启服务与重新挂载可能需要较长时间，
+// 因此放到独立的 goroutine 中，并按重载的先后顺序执行
+func applyConfigChanges(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger) {
+	configChangesOnce.Do(func() {
+		go func() {
+			for set := range configChanges {
+				dispatchConfigChange(set)
+			}
+		}()
+	})
+	configChanges <- configChangeSet{old: old, new: new, diff: diff, logger: logger}
+}
+
+func dispatchConfigChange(set configChangeSet) {
+	configChangeMu.Lock()
+	handlers := append([]configChangeHandler(nil), configChangeHandlers...)
+	configChangeMu.Unlock()
+	for _, h := range handlers {
+		if !set.diff.Changed(h.sections...) {
+			continue
+		}
+		set.logger.Debugf("[config] applying changes to %s", h.name)
+		h.apply(set.old, set.new, set.diff, set.logger)
+	}
+}
+
+// applyServerChange 监听端口与路由无法在运行中切换，只提示需要重启
+func applyServerChange(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger) {
+	var changed []string
+	for _, p := range restartRequiredPaths {
+		if diff.Changed(p) {
+			changed = append(changed, p)
+		}
+	}
+	logger.Warnf("[config] %s changed, restart nascore to apply", strings.Join(changed, ", "))
+}
+
+// applyEventConfig 更新日志脱敏的通配符，并按新配置重新订阅 Webhooks 与通知，配置未变化的部分不做任何事。
+// 启动时也由它完成首次订阅，此时 old 与 diff 为空
+func applyEventConfig(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger) {
+	secretmask.Default().SetPatterns(new.ThirdPartyExt.SecretEnvPatterns)
+	secretmask.Default().AddEnv(os.Environ()) // 通过进程环境传入、在命令块中以 ${VAR} 引用的密钥
+	eventbus.ApplyWebhookConfig(new, logger)
+	notify.ApplyConfig(new, logger)
+}
+
+// serviceChangeHandler 托管的第三方程序：AutoStartEnable 关闭时停止，运行中且启动参数或守护配置变化时按新配置重启。
+// 重新开启时只清除启动标记，由 loopCheckFollowStart 按依赖顺序启动
+func serviceChangeHandler(name, section string, started *int32, spec func(*system_config.SysCfg) exeStart.ServiceSpec, enabled func(*system_config.SysCfg) bool) configChangeHandler {
+	apply := func(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger) {
+		if atomic.LoadInt32(started) == 0 {
+			return // 尚未启动，启动时会使用新配置
+		}
+		if !enabled(new) {
+			logger.Debugf("[config] %s AutoStartEnable turned off, stopping", name)
+			exeStart.StopSpec(spec(old), logger)
+			atomic.StoreInt32(started, 0)
+			return
+		}
+		if reflect.DeepEqual(spec(old), spec(new)) {
+			return
+		}
+		logger.Debugf("[config] %s config changed, restarting", name)
+		exeStart.StopSpec(spec(old), logger) // 按旧的 pid 文件与程序路径结束旧进程
+		if err := exeStart.StartSpec(spec(new), logger); err != nil {
+			logger.Warnf("[config] restart %s err: %v", name, err)
+		}
+	}
+	return configChangeHandler{name: name, sections: append([]string{section}, serviceCommonPaths...), apply: apply}
+}
+
+// applyServicesRegistration 按新配置重新注册第三方程序，服务管理接口只读取注册的结果
+func applyServicesRegistration(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger) {
+	exeStart.RegisterThirdPartyServices(new, logger)
+}
+
+// applyRcloneChange AutoMountEnable 关闭时停止挂载服务并按旧配置卸载；挂载相关的配置变化时按旧配置卸载后按新配置重新挂载
+func applyRcloneChange(old, new *system_config.SysCfg, diff system_config.ConfigDiff, logger *zap.SugaredLogger) {
+	if atomic.LoadInt32(&isRcloneMountFollowStart) == 0 {
+		return
+	}
+	if !new.ThirdPartyExt.Rclone.AutoMountEnable {
+		logger.Debugf("[config] rclone AutoMountEnable turned off, unmounting")
+		stopRcloneMounts(old, logger)
+		atomic.StoreInt32(&isRcloneMountFollowStart, 0)
+		return
+	}
+	mountPaths := append([]string{
+		"ThirdPartyExt.Rclone.AutoMountCommand",
+		"ThirdPartyExt.Rclone.AutoUnMountCommand",
+		"ThirdPartyExt.Rclone.BinPath",
+		"ThirdPartyExt.Rclone.ConfigFilePath",
+		"ThirdPartyExt.Rclone.Supervise",
+	}, serviceCommonPaths...)
+	if !diff.Changed(mountPaths...) {
+		return // 只改了定时重新挂载等，由调度器按新配置执行
+	}
+	logger.Debugf("[config] rclone mount config changed, remounting")
+	stopRcloneMounts(old, logger)
+	if _, err := exeRcloneAutoMount(context.Background(), new, logger); err != nil {
+		logger.Warnf("[config] rclone remount err: %v", err)
+	}
+}
+
+// stopRcloneMounts 停止所有 rclone 挂载服务，再执行旧配置中的 AutoUnMountCommand
+func stopRcloneMounts(old *system_config.SysCfg, logger *zap.SugaredLogger) {
+	ctx, cancel := context.WithTimeout(context.Background(), unmountTimeout)
+	defer cancel()
+	stopRcloneThenUnmount(ctx, old, logger, func() { rcloneServiceGroup(old, logger).prune(nil, logger) })
+}
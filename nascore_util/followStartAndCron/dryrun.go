@@ -0,0 +1,165 @@
+package followStartAndCron
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nas-core/nascore/nascore_util/cmdline"
+	"github.com/nas-core/nascore/nascore_util/secretmask"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+// 可预览的命令块
+const (
+	CommandBlockLego          = "lego"           // AcmeLego.Command
+	CommandBlockRcloneMount   = "rclone-mount"   // Rclone.AutoMountCommand
+	CommandBlockRcloneUnmount = "rclone-unmount" // Rclone.AutoUnMountCommand
+)
+
+// ErrUnknownCommandBlock 不支持预览的命令块
+var ErrUnknownCommandBlock = errors.New("unknown command block")
+
+// DryRunLine 命令块中一条命令的预览
+type DryRunLine struct {
+	Line     int      `json:"line"`
+	Command  string   `json:"command"`           // 原始文本，密钥显示为 ***
+	Argv     []string `json:"argv"`              // 变量展开后的命令及参数
+	Env      []string `json:"env,omitempty"`     // 追加到进程环境之后的 KEY=VALUE，密钥显示为 ***
+	Unset    []string `json:"unset,omitempty"`   // 从进程环境中移除的变量
+	Mode     string   `json:"mode"`              // sequential 或 background
+	Service  string   `json:"service,omitempty"` // 后台命令作为托管服务运行时的服务名
+	Path     string   `json:"path,omitempty"`    // LookPath 找到的可执行文件
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DryRunResult 命令块的预览结果。Error 不为空时命令块有语法错误，执行时一行也不会运行
+type DryRunResult struct {
+	Block    string              `json:"block"`
+	Vars     map[string]string   `json:"vars"` // 可用的占位符及其值
+	Error    *cmdline.ParseError `json:"error,omitempty"`
+	Lines    []DryRunLine        `json:"lines"`
+	Warnings []string            `json:"warnings,omitempty"` // 与具体行无关的提示
+}
+
+// DryRunCommandBlock 按执行时的方式解析命令块但不运行，返回每行展开后的命令、环境变量与运行方式，
+// 并提示未定义的变量、找不到的可执行文件与相对路径。script 为空时预览当前配置中的命令块
+func DryRunCommandBlock(nsCfg *system_config.SysCfg, block, script string) (DryRunResult, error) {
+	var vars map[string]string
+	var group *serviceGroup // 只用于计算服务名
+	switch block {
+	case CommandBlockLego:
+		vars = legoCommandVars(nsCfg)
+		group = &serviceGroup{prefix: serviceGroupLego}
+		if script == "" {
+			script = nsCfg.ThirdPartyExt.AcmeLego.Command
+		}
+	case CommandBlockRcloneMount:
+		vars = rcloneCommandVars(nsCfg)
+		group = &serviceGroup{prefix: serviceGroupRclone}
+		if script == "" {
+			script = nsCfg.ThirdPartyExt.Rclone.AutoMountCommand
+		}
+	case CommandBlockRcloneUnmount:
+		vars = rcloneCommandVars(nsCfg)
+		if script == "" {
+			script = nsCfg.ThirdPartyExt.Rclone.AutoUnMountCommand
+		}
+	default:
+		return DryRunResult{}, fmt.Errorf("%w %q", ErrUnknownCommandBlock, block)
+	}
+
+	masker := secretmask.New(nsCfg.ThirdPartyExt.SecretEnvPatterns)
+	masker.AddEnv(os.Environ())
+	result := DryRunResult{Block: block, Vars: vars, Lines: []DryRunLine{}}
+	commands, err := parseCommandBlock(script, vars)
+	if err != nil {
+		var perr *cmdline.ParseError
+		if !errors.As(err, &perr) {
+			return DryRunResult{}, err
+		}
+		result.Error = perr
+		return result, nil
+	}
+	for _, c := range commands {
+		masker.AddEnv(c.Env)
+	}
+
+	wd, _ := os.Getwd()
+	usedNames := make(map[string]bool)
+	background := 0
+	for _, c := range commands {
+		if c.Assign {
+			continue
+		}
+		line := DryRunLine{
+			Line:    c.Line,
+			Command: masker.String(c.Raw),
+			Argv:    masker.Strings(c.Args),
+			Env:     masker.Env(c.Env),
+			Unset:   c.Unset,
+			Mode:    "sequential",
+		}
+		if c.Background {
+			background++
+			line.Mode = "background"
+			if group != nil {
+				line.Service = group.serviceName(c, background, usedNames)
+			}
+		}
+		for _, name := range c.Unknown {
+			line.Warnings = append(line.Warnings, fmt.Sprintf("undefined variable ${%s} expands to empty", name))
+		}
+		path, err := exec.LookPath(c.Args[0])
+		switch {
+		case errors.Is(err, exec.ErrDot): // PATH 中包含 . 时按名称找到了当前目录下的文件，执行时会被拒绝
+			line.Path = path
+			line.Warnings = append(line.Warnings, fmt.Sprintf("executable %s resolves to the working directory %s, use ./%s or an absolute path", c.Args[0], wd, c.Args[0]))
+		case err != nil:
+			line.Warnings = append(line.Warnings, fmt.Sprintf("executable %s not found: %v", c.Args[0], unwrapExecErr(err)))
+		default:
+			line.Path = path
+		}
+		for _, arg := range c.Args {
+			if p := relativePathArg(arg); p != "" {
+				line.Warnings = append(line.Warnings, fmt.Sprintf("relative path %s resolves against working directory %s", masker.String(p), wd))
+			}
+		}
+		result.Lines = append(result.Lines, line)
+	}
+	if len(result.Lines) == 0 {
+		result.Warnings = append(result.Warnings, "command block has no commands")
+	}
+	return result, nil
+}
+
+// relativePathArg 参数本身或 --flag=值 中的值以 ./ 或 ../ 开头时返回该路径
+func relativePathArg(arg string) string {
+	if strings.HasPrefix(arg, "-") {
+		_, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			return ""
+		}
+		arg = v
+	}
+	for _, prefix := range []string{"./", "../", ".\\", "..\\"} {
+		if strings.HasPrefix(arg, prefix) {
+			return arg
+		}
+	}
+	if arg == "." || arg == ".." {
+		return arg
+	}
+	return "" // 例如 remote:path 与 a/b 这类参数不一定是本地路径，不提示
+}
+
+// unwrapExecErr 只保留 exec.Error 的底层原因，文件名已在提示中
+func unwrapExecErr(err error) error {
+	var eerr *exec.Error
+	if errors.As(err, &eerr) {
+		return eerr.Err
+	}
+	return err
+}
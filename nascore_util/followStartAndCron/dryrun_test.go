@@ -0,0 +1,112 @@
+package followStartAndCron
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+func TestDryRunCommandBlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as executables")
+	}
+	bin := t.TempDir()
+	for _, name := range []string{"rclone", "lego"} {
+		os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0755)
+	}
+	wd := t.TempDir()
+	os.WriteFile(filepath.Join(wd, "localtool"), []byte("#!/bin/sh\n"), 0755)
+	t.Chdir(wd)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+".")
+
+	cfg := system_config.NewDefaultConfig()
+	cfg.ThirdPartyExt.Rclone.BinPath = filepath.Join(bin, "rclone")
+	cfg.ThirdPartyExt.Rclone.ConfigFilePath = "/etc/rclone.conf"
+	cfg.ThirdPartyExt.Rclone.AutoMountCommand = "${BinPath} mount a: /mnt/a ${ConfigFilePath} &nascore"
+
+	tests := []struct {
+		name         string
+		block        string
+		script       string
+		wantArgv     [][]string
+		wantMode     []string
+		wantService  []string
+		wantWarnings []string // 按行拼接后依次包含的内容，数量必须一致
+	}{
+		{"current config", CommandBlockRcloneMount, "",
+			[][]string{{filepath.Join(bin, "rclone"), "mount", "a:", "/mnt/a", "--config=/etc/rclone.conf"}},
+			[]string{"background"}, []string{"rclone-a"}, nil},
+		{"undefined variable and relative path", CommandBlockRcloneMount, "rclone mount ${NASCORE_TEST_UNDEFINED}a: ./mnt",
+			[][]string{{"rclone", "mount", "a:", "./mnt"}},
+			[]string{"sequential"}, []string{""},
+			[]string{"undefined variable ${NASCORE_TEST_UNDEFINED} expands to empty", "relative path ./mnt resolves against working directory " + wd}},
+		{"relative flag value", CommandBlockLego, "lego --path=../certs run",
+			[][]string{{"lego", "--path=../certs", "run"}},
+			[]string{"sequential"}, []string{""},
+			[]string{"relative path ../certs"}},
+		{"executable not found", CommandBlockRcloneUnmount, "fusermount -u /mnt/a",
+			[][]string{{"fusermount", "-u", "/mnt/a"}},
+			[]string{"sequential"}, []string{""},
+			[]string{"executable fusermount not found"}},
+		{"executable in working directory", CommandBlockLego, "localtool &nascore\nlocaltool &nascore",
+			[][]string{{"localtool"}, {"localtool"}},
+			[]string{"background", "background"}, []string{"lego-1", "lego-2"},
+			[]string{"executable localtool resolves to the working directory " + wd, "executable localtool resolves to the working directory"}},
+		{"secrets are masked", CommandBlockLego, "export CF_API_TOKEN=supersecret\nlego --token=${CF_API_TOKEN} run",
+			[][]string{{"lego", "--token=***", "run"}},
+			[]string{"sequential"}, []string{""}, nil},
+	}
+	for _, tt := range tests {
+		res, err := DryRunCommandBlock(cfg, tt.block, tt.script)
+		if err != nil || res.Error != nil {
+			t.Errorf("%s: err = %v, parse error = %v", tt.name, err, res.Error)
+			continue
+		}
+		var argv [][]string
+		var modes, services, warnings []string
+		for _, l := range res.Lines {
+			argv = append(argv, l.Argv)
+			modes = append(modes, l.Mode)
+			services = append(services, l.Service)
+			warnings = append(warnings, l.Warnings...)
+		}
+		if !slices.EqualFunc(argv, tt.wantArgv, slices.Equal) || !slices.Equal(modes, tt.wantMode) || !slices.Equal(services, tt.wantService) {
+			t.Errorf("%s: argv %q modes %v services %v", tt.name, argv, modes, services)
+		}
+		if len(warnings) != len(tt.wantWarnings) {
+			t.Errorf("%s: warnings = %q, want %d", tt.name, warnings, len(tt.wantWarnings))
+			continue
+		}
+		for i, w := range tt.wantWarnings {
+			if !strings.Contains(warnings[i], w) {
+				t.Errorf("%s: warning %d = %q, want it to contain %q", tt.name, i, warnings[i], w)
+			}
+		}
+	}
+
+	res, _ := DryRunCommandBlock(cfg, CommandBlockLego, "export CF_API_TOKEN=supersecret\nlego run")
+	if env := res.Lines[0].Env; !slices.Equal(env, []string{"CF_API_TOKEN=***"}) || strings.Contains(res.Lines[0].Command, "supersecret") {
+		t.Errorf("secret env not masked: %+v", res.Lines[0])
+	}
+}
+
+func TestDryRunCommandBlockErrors(t *testing.T) {
+	cfg := system_config.NewDefaultConfig()
+	res, err := DryRunCommandBlock(cfg, CommandBlockLego, "lego\nlego 'unterminated")
+	if err != nil || res.Error == nil || res.Error.Line != 2 || len(res.Lines) != 0 {
+		t.Fatalf("syntax error: err = %v, result = %+v", err, res)
+	}
+	res, err = DryRunCommandBlock(cfg, CommandBlockLego, "# only a comment\nexport A=1")
+	if err != nil || !slices.Equal(res.Warnings, []string{"command block has no commands"}) {
+		t.Fatalf("empty block: err = %v, warnings = %v", err, res.Warnings)
+	}
+	if _, err := DryRunCommandBlock(cfg, "caddy", "caddy run"); !errors.Is(err, ErrUnknownCommandBlock) {
+		t.Fatalf("unknown block err = %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package followStartAndCron
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 
@@ -10,28 +11,34 @@ import (
 	"go.uber.org/zap"
 )
 
-func execADGuardsGetRules(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
-	err := DownloadADGuardRules(&nsCfg.ThirdPartyExt.AdGuard.Upstream_dns_fileUpdateUrl, &nsCfg.ThirdPartyExt.GitHubDownloadMirror, &nsCfg.ThirdPartyExt.AdGuard.Upstream_dns_file)
+func execADGuardsGetRules(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) error {
+	err := DownloadADGuardRulesContext(ctx, &nsCfg.ThirdPartyExt.AdGuard.Upstream_dns_fileUpdateUrl, &nsCfg.ThirdPartyExt.GitHubDownloadMirror, &nsCfg.ThirdPartyExt.AdGuard.Upstream_dns_file)
 	if err != nil {
 		logger.Errorw("Download ADGuard rules failed", "error", err)
 	}
+	return err
 }
 
 func DownloadADGuardRules(Upstream_dns_fileUpdateUrl *string, GitHubDownloadMirror *string, Upstream_dns_file *string) error {
+	return DownloadADGuardRulesContext(context.Background(), Upstream_dns_fileUpdateUrl, GitHubDownloadMirror, Upstream_dns_file)
+}
+
+// DownloadADGuardRulesContext 与 DownloadADGuardRules 相同，ctx 取消或超时后中止下载
+func DownloadADGuardRulesContext(ctx context.Context, Upstream_dns_fileUpdateUrl *string, GitHubDownloadMirror *string, Upstream_dns_file *string) error {
 	DownLoadlink := *Upstream_dns_fileUpdateUrl
 
-	if len(*GitHubDownloadMirror) > len("https://") {
-		if !strings.HasSuffix(*GitHubDownloadMirror, "/") {
-			*GitHubDownloadMirror += "/"
+	if mirror := *GitHubDownloadMirror; len(mirror) > len("https://") {
+		if !strings.HasSuffix(mirror, "/") {
+			mirror += "/" // 不修改传入的配置，它可能是已发布的只读快照
 		}
 		if strings.Contains(DownLoadlink, "github.com/") || strings.Contains(DownLoadlink, "raw.githubusercontent.com/") {
-			DownLoadlink = *GitHubDownloadMirror + DownLoadlink
+			DownLoadlink = mirror + DownLoadlink
 		}
 	}
 	saveFilename := filepath.Base(*Upstream_dns_file)
 	SaveDir := filepath.Dir(*Upstream_dns_file)
 
-	_, err := downfile.DownloadFile(DownLoadlink, SaveDir, saveFilename)
+	_, err := downfile.DownloadFileContext(ctx, DownLoadlink, SaveDir, saveFilename)
 	if err != nil {
 		return err
 	}
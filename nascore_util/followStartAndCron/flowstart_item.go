@@ -1,6 +1,8 @@
 package followStartAndCron
 
 import (
+	"context"
+
 	"github.com/nas-core/nascore/nascore_util/exeStart"
 	"github.com/nas-core/nascore/nascore_util/system_config"
 
@@ -8,27 +10,21 @@ import (
 )
 
 func DdnsSGOFollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (err error) {
-	exeStart.KillDDNSGo(nsCfg, logger)
-	err = exeStart.StartDDNSGo(nsCfg, logger)
-	return err
+	return exeStart.StartSpec(exeStart.DDNSGoSpec(nsCfg), logger)
 }
 
 // ./openlist server --data ./oplist_data
 func OpenlistFollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (err error) {
-	exeStart.KillOpenlist(nsCfg, logger)
-	err = exeStart.StartOpenlist(nsCfg, logger)
-	return err
+	return exeStart.StartSpec(exeStart.OpenlistSpec(nsCfg), logger)
 }
 
 func Caddy2FollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (err error) {
-	exeStart.KillCaddy2(nsCfg, logger)
-	err = exeStart.StartCaddy2(nsCfg, logger)
-	return err
+	return exeStart.StartSpec(exeStart.Caddy2Spec(nsCfg), logger)
 }
 
 // RcloneFollowStart executes rclone mount commands from system configuration
 func RcloneFollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (err error) {
-	exeRcloneAutoMount(nsCfg, logger)
+	exeRcloneAutoMount(context.Background(), nsCfg, logger)
 
 	return nil
 }
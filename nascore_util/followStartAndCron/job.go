@@ -0,0 +1,179 @@
+package followStartAndCron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobExists   = errors.New("job already registered")
+	ErrJobRunning  = errors.New("job is already running")
+	ErrJobBuiltin  = errors.New("builtin job cannot be unregistered")
+	ErrJobTimeout  = errors.New("job timed out")
+)
+
+const (
+	defaultJobTimeout = 30 * time.Minute
+	jobCancelGrace    = 10 * time.Second // 超时后等待任务自行返回的时间，超过后不再等待
+)
+
+// Job 计划任务。Schedule 返回 cron 表达式，为空表示不自动运行，仍可手动触发
+type Job interface {
+	Name() string
+	Schedule(nsCfg *system_config.SysCfg) string
+	Run(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (output string, err error)
+}
+
+// JobWithTimeout 可选接口，返回任务单次运行的超时时间，未实现或返回 0 时使用 defaultJobTimeout
+type JobWithTimeout interface {
+	Timeout(nsCfg *system_config.SysCfg) time.Duration
+}
+
+// jobTimeout 返回任务单次运行的超时时间
+func jobTimeout(job Job, nsCfg *system_config.SysCfg) time.Duration {
+	if j, ok := job.(JobWithTimeout); ok {
+		if d := j.Timeout(nsCfg); d > 0 {
+			return d
+		}
+	}
+	return defaultJobTimeout
+}
+
+// FuncJob 用函数实现的 Job
+type FuncJob struct {
+	JobName    string
+	Spec       func(nsCfg *system_config.SysCfg) string
+	TimeoutSec func(nsCfg *system_config.SysCfg) int // 可为 nil
+	Fn         func(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error)
+}
+
+func (j FuncJob) Name() string { return j.JobName }
+
+func (j FuncJob) Schedule(nsCfg *system_config.SysCfg) string {
+	if j.Spec == nil {
+		return ""
+	}
+	return j.Spec(nsCfg)
+}
+
+func (j FuncJob) Timeout(nsCfg *system_config.SysCfg) time.Duration {
+	if j.TimeoutSec == nil {
+		return 0
+	}
+	return time.Duration(j.TimeoutSec(nsCfg)) * time.Second
+}
+
+func (j FuncJob) Run(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+	return j.Fn(ctx, nsCfg, logger)
+}
+
+// ExtensionJob 由扩展程序注册的任务，运行时通过扩展的 unix socket 以 POST 请求 Path
+type ExtensionJob struct {
+	JobName    string `json:"name"`
+	Spec       string `json:"schedule"`
+	Extension  string `json:"extension"` // system_config.ExtensionSocketMap 中的扩展名
+	Path       string `json:"path"`
+	TimeoutSec int    `json:"timeout_sec"`
+}
+
+func (j ExtensionJob) Name() string { return j.JobName }
+
+func (j ExtensionJob) Schedule(nsCfg *system_config.SysCfg) string { return j.Spec }
+
+func (j ExtensionJob) Timeout(nsCfg *system_config.SysCfg) time.Duration {
+	return time.Duration(j.TimeoutSec) * time.Second
+}
+
+func (j ExtensionJob) Run(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+	socketFile, ok := system_config.ExtensionSocketMap[j.Extension]
+	if !ok {
+		return "", fmt.Errorf("unknown extension %q", j.Extension)
+	}
+	socketPath := system_config.EnsureDirPathSuffix(nsCfg.Server.TempFilePath) + socketFile
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://unix/"+strings.TrimPrefix(j.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxJobOutput))
+	if resp.StatusCode/100 != 2 {
+		return string(body), fmt.Errorf("extension %s returned status %d", j.Extension, resp.StatusCode)
+	}
+	return string(body), nil
+}
+
+// JobInfo 任务的调度状态
+type JobInfo struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	Builtin   bool      `json:"builtin"`
+	Paused    bool      `json:"paused"`
+	Running   bool      `json:"running"`
+	LastRun   time.Time `json:"last_run"`
+	NextRun   time.Time `json:"next_run"`
+	SpecError string    `json:"spec_error,omitempty"`
+}
+
+// RegisterJob 注册计划任务，扩展程序可通过它加入自己的任务
+func RegisterJob(job Job) error {
+	return scheduler.register(job, false)
+}
+
+// UnregisterJob 移除通过 RegisterJob 注册的任务，内置任务不能移除
+func UnregisterJob(name string) error {
+	return scheduler.unregister(name)
+}
+
+// ListJobs 返回所有任务的调度状态，按注册顺序排列
+func ListJobs(nsCfg *system_config.SysCfg) []JobInfo {
+	return scheduler.list(nsCfg)
+}
+
+// GetJob 返回单个任务的调度状态
+func GetJob(nsCfg *system_config.SysCfg, name string) (JobInfo, error) {
+	for _, info := range scheduler.list(nsCfg) {
+		if info.Name == name {
+			return info, nil
+		}
+	}
+	return JobInfo{}, ErrJobNotFound
+}
+
+// RunJobNow 立即在后台运行任务，不影响之后的调度，暂停中的任务也可以手动运行
+func RunJobNow(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, name string) error {
+	return scheduler.runNow(nsCfg, logger, name, false)
+}
+
+// PauseJob 暂停任务的自动调度，暂停状态保存在数据库中，重启后仍然有效
+func PauseJob(name string) error {
+	return scheduler.setPaused(name, true)
+}
+
+// ResumeJob 恢复任务的自动调度
+func ResumeJob(name string) error {
+	return scheduler.setPaused(name, false)
+}
@@ -0,0 +1,237 @@
+package followStartAndCron
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CronSqliteDB 计划任务运行记录所在的数据库，即 nascore.db，由 OpenCronSqliteDB 打开，为 nil 时使用 VodSqliteDB
+var CronSqliteDB *sql.DB
+
+// 按顺序查找主程序注册的 sqlite 驱动：mattn/go-sqlite3 为 sqlite3，modernc.org/sqlite 为 sqlite
+var sqliteDriverNames = []string{"sqlite3", "sqlite"}
+
+// OpenCronSqliteDB 打开 path 处的 sqlite 数据库（不存在时创建）并设置为 CronSqliteDB。
+// 本包不引入具体的驱动，使用主程序已注册的 sqlite 驱动
+func OpenCronSqliteDB(path string) error {
+	drivers := sql.Drivers()
+	i := slices.IndexFunc(sqliteDriverNames, func(name string) bool { return slices.Contains(drivers, name) })
+	if i < 0 {
+		return fmt.Errorf("no sqlite driver registered")
+	}
+	db, err := sql.Open(sqliteDriverNames[i], path)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(1) // 写入很少，单连接避免 database is locked
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	CronSqliteDB = db
+	return nil
+}
+
+const (
+	JobStatusRunning = "running"
+	JobStatusSuccess = "success"
+	JobStatusFailed  = "failed"
+	JobStatusTimeout = "timeout"
+
+	jobHistoryKeep = 200       // 每个任务保留的运行记录条数
+	maxJobOutput   = 64 * 1024 // 每条记录保存的输出字节数上限
+)
+
+// JobRun 一次任务运行的记录
+type JobRun struct {
+	ID         int64     `json:"id"`
+	Job        string    `json:"job"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     string    `json:"status"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Manual     bool      `json:"manual"` // 手动运行，不参与调度时间的推算
+}
+
+var (
+	jobTableMu    sync.Mutex
+	jobTableReady *sql.DB // 已建表的数据库，切换数据库后重新建表
+)
+
+// jobHistoryAvailable 是否已设置用于保存运行记录的数据库
+func jobHistoryAvailable() bool {
+	return CronSqliteDB != nil || VodSqliteDB != nil
+}
+
+// jobHistoryDB 返回可用的数据库，表不存在时自动创建
+func jobHistoryDB() (*sql.DB, error) {
+	db := CronSqliteDB
+	if db == nil {
+		db = VodSqliteDB
+	}
+	if db == nil {
+		return nil, nil
+	}
+	jobTableMu.Lock()
+	defer jobTableMu.Unlock()
+	if jobTableReady == db {
+		return db, nil
+	}
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS cron_job_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job TEXT NOT NULL,
+		started_at INTEGER NOT NULL,
+		finished_at INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_cron_job_runs_job ON cron_job_runs (job, started_at)`); err != nil {
+		return nil, err
+	}
+	// 旧版本建的表没有 output 与 manual 列
+	if _, err := db.Exec(`ALTER TABLE cron_job_runs ADD COLUMN output TEXT NOT NULL DEFAULT ''`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return nil, err
+	}
+	if _, err := db.Exec(`ALTER TABLE cron_job_runs ADD COLUMN manual INTEGER NOT NULL DEFAULT 0`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS cron_job_paused (job TEXT PRIMARY KEY, paused_at INTEGER NOT NULL)`); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS cron_job_leases (job TEXT PRIMARY KEY, owner TEXT NOT NULL, expires_at INTEGER NOT NULL)`); err != nil {
+		return nil, err
+	}
+	jobTableReady = db
+	return db, nil
+}
+
+// recordJobStart 写入一条运行中的记录，返回记录 id。没有数据库时只把调度运行的开始时间写入数据目录，返回 0
+func recordJobStart(job string, startedAt time.Time, manual bool) (int64, error) {
+	db, err := jobHistoryDB()
+	if err != nil {
+		return 0, err
+	}
+	if db == nil {
+		if manual {
+			return 0, nil
+		}
+		return 0, writeFileLastStart(job, startedAt)
+	}
+	res, err := db.Exec(`INSERT INTO cron_job_runs (job, started_at, status, manual) VALUES (?, ?, ?, ?)`, job, startedAt.UnixMilli(), JobStatusRunning, manual)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// recordJobFinish 更新运行结果与输出，并清理超出保留条数的旧记录
+func recordJobFinish(id int64, job string, startedAt time.Time, output string, runErr error) error {
+	db, err := jobHistoryDB()
+	if db == nil || err != nil || id == 0 {
+		return err
+	}
+	finishedAt := time.Now()
+	status, errStr := JobStatusSuccess, ""
+	if errors.Is(runErr, ErrJobTimeout) {
+		status, errStr = JobStatusTimeout, runErr.Error()
+	} else if runErr != nil {
+		status, errStr = JobStatusFailed, runErr.Error()
+	}
+	if len(output) > maxJobOutput {
+		output = output[len(output)-maxJobOutput:] // 保留结尾，错误信息通常在最后
+	}
+	_, err = db.Exec(`UPDATE cron_job_runs SET finished_at = ?, status = ?, duration_ms = ?, error = ?, output = ? WHERE id = ?`,
+		finishedAt.UnixMilli(), status, finishedAt.Sub(startedAt).Milliseconds(), errStr, output, id)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`DELETE FROM cron_job_runs WHERE job = ? AND id NOT IN (SELECT id FROM cron_job_runs WHERE job = ? ORDER BY id DESC LIMIT ?)`, job, job, jobHistoryKeep)
+	return err
+}
+
+// lastJobStart 返回任务最近一次按调度开始运行的时间，手动运行不计入，没有记录时返回零值
+func lastJobStart(job string) (time.Time, error) {
+	db, err := jobHistoryDB()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if db == nil {
+		return readFileLastStart(job)
+	}
+	var ms sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(started_at) FROM cron_job_runs WHERE job = ? AND manual = 0`, job).Scan(&ms); err != nil {
+		return time.Time{}, err
+	}
+	if !ms.Valid {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(ms.Int64), nil
+}
+
+// JobHistory 返回任务最近的 limit 条运行记录，新的在前
+func JobHistory(job string, limit int) ([]JobRun, error) {
+	db, err := jobHistoryDB()
+	if db == nil || err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := db.Query(`SELECT id, job, started_at, finished_at, status, duration_ms, error, output, manual FROM cron_job_runs WHERE job = ? ORDER BY id DESC LIMIT ?`, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []JobRun
+	for rows.Next() {
+		var r JobRun
+		var started, finished int64
+		if err := rows.Scan(&r.ID, &r.Job, &started, &finished, &r.Status, &r.DurationMs, &r.Error, &r.Output, &r.Manual); err != nil {
+			return nil, err
+		}
+		r.StartedAt = time.UnixMilli(started)
+		if finished > 0 {
+			r.FinishedAt = time.UnixMilli(finished)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// isJobPaused 任务是否被暂停
+func isJobPaused(job string) (bool, error) {
+	db, err := jobHistoryDB()
+	if db == nil || err != nil {
+		return false, err
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM cron_job_paused WHERE job = ?`, job).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// saveJobPaused 保存任务的暂停状态，没有数据库时只在内存中生效
+func saveJobPaused(job string, paused bool) error {
+	db, err := jobHistoryDB()
+	if db == nil || err != nil {
+		return err
+	}
+	if paused {
+		_, err = db.Exec(`INSERT INTO cron_job_paused (job, paused_at) VALUES (?, ?) ON CONFLICT(job) DO NOTHING`, job, time.Now().Unix())
+	} else {
+		_, err = db.Exec(`DELETE FROM cron_job_paused WHERE job = ?`, job)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package followStartAndCron
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestJobHistoryInCronSqliteDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nascore.db")
+	if err := OpenCronSqliteDB(path); err != nil {
+		t.Fatalf("OpenCronSqliteDB: %v", err)
+	}
+	t.Cleanup(func() {
+		CronSqliteDB.Close()
+		CronSqliteDB = nil
+	})
+	if !jobHistoryAvailable() {
+		t.Fatal("job history should be available after OpenCronSqliteDB")
+	}
+
+	started := time.Now().Add(-time.Second).Truncate(time.Millisecond)
+	id, err := recordJobStart("backup", started, false)
+	if err != nil || id == 0 {
+		t.Fatalf("recordJobStart: id=%d err=%v", id, err)
+	}
+	if err := recordJobFinish(id, "backup", started, "done", errors.New("exit status 1")); err != nil {
+		t.Fatalf("recordJobFinish: %v", err)
+	}
+	last, err := lastJobStart("backup")
+	if err != nil || !last.Equal(started) {
+		t.Fatalf("lastJobStart = %v, %v, want %v", last, err, started)
+	}
+
+	// 手动运行写入运行记录，但不作为调度的最近运行时间
+	manualID, err := recordJobStart("backup", started.Add(time.Millisecond), true)
+	if err != nil {
+		t.Fatalf("recordJobStart manual: %v", err)
+	}
+	if err := recordJobFinish(manualID, "backup", started, "", nil); err != nil {
+		t.Fatalf("recordJobFinish manual: %v", err)
+	}
+	if last, err := lastJobStart("backup"); err != nil || !last.Equal(started) {
+		t.Fatalf("lastJobStart after a manual run = %v, %v, want %v", last, err, started)
+	}
+
+	runs, err := JobHistory("backup", 10)
+	if err != nil {
+		t.Fatalf("JobHistory: %v", err)
+	}
+	if len(runs) != 2 || !runs[0].Manual || runs[0].Status != JobStatusSuccess {
+		t.Fatalf("JobHistory manual run = %+v", runs)
+	}
+	if r := runs[1]; r.Manual || r.Status != JobStatusFailed || r.Output != "done" || r.Error != "exit status 1" {
+		t.Fatalf("JobHistory = %+v", runs)
+	}
+
+	if err := saveJobPaused("backup", true); err != nil {
+		t.Fatalf("saveJobPaused: %v", err)
+	}
+	if paused, err := isJobPaused("backup"); err != nil || !paused {
+		t.Fatalf("isJobPaused = %v, %v, want true", paused, err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database file not created: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package followStartAndCron
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// leaseOwner 当前进程的租约持有者标识，多个实例共享同一个数据目录时用来区分彼此
+var leaseOwner = func() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}()
+
+const (
+	jobLeaseMargin        = time.Minute      // 租约在任务超时之外额外保留的时间
+	jobLeaseRenewInterval = 30 * time.Second // 续期间隔，小于 jobLeaseMargin，两次续期之间租约不会过期
+)
+
+// acquireJobLease 获取任务的运行租约，同一时间只有一个实例能持有。
+// 有数据库时租约保存在 cron_job_leases 表中，否则使用数据目录下带过期时间的锁文件。
+// 持有期间每隔 jobLeaseRenewInterval 把过期时间延长到 ttl 之后，超时后被放弃但仍在运行的任务也一直持有租约；
+// 持有者异常退出后租约在 ttl 后自动失效，不会永久占用
+func acquireJobLease(job string, ttl time.Duration, logger *zap.SugaredLogger) (release func(), ok bool, err error) {
+	db, err := jobHistoryDB()
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+	if db == nil {
+		drop, ok, err := acquireFileLease(job, now, ttl)
+		if !ok || err != nil {
+			return nil, false, err
+		}
+		return keepLeaseAlive(job, ttl, func(expires time.Time) (bool, error) { return renewFileLease(job, expires) }, drop, logger), true, nil
+	}
+	res, err := db.Exec(`INSERT INTO cron_job_leases (job, owner, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(job) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+		WHERE cron_job_leases.expires_at < ? OR cron_job_leases.owner = excluded.owner`,
+		job, leaseOwner, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return nil, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, false, err
+	}
+	renew := func(expires time.Time) (bool, error) {
+		res, err := db.Exec(`UPDATE cron_job_leases SET expires_at = ? WHERE job = ? AND owner = ?`, expires.UnixMilli(), job, leaseOwner)
+		if err != nil {
+			return true, err
+		}
+		n, err := res.RowsAffected()
+		return n > 0, err
+	}
+	drop := func() {
+		db.Exec(`DELETE FROM cron_job_leases WHERE job = ? AND owner = ?`, job, leaseOwner)
+	}
+	return keepLeaseAlive(job, ttl, renew, drop, logger), true, nil
+}
+
+// keepLeaseAlive 定时调用 renew 续期直到返回的 release 被调用，release 停止续期后再调用 drop 删除租约。
+// renew 返回 false 表示租约已被其它实例取得，此时只记录错误，任务不会被中断
+func keepLeaseAlive(job string, ttl time.Duration, renew func(expires time.Time) (bool, error), drop func(), logger *zap.SugaredLogger) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(jobLeaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				held, err := renew(now.Add(ttl))
+				if err != nil {
+					logger.Warnf("[cron] renew lease of %s err: %v", job, err)
+					continue
+				}
+				if !held {
+					logger.Errorf("[cron] lease of %s was taken by another instance while the job is still running", job)
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+			drop()
+		})
+	}
+}
+
+// cronLockDir 没有数据库时保存锁文件和最近运行时间的目录，与 nascore.db 位于同一目录
+func cronLockDir() string {
+	return filepath.Join(filepath.Dir(system_config.DbUserPath), "cron_locks")
+}
+
+// acquireFileLease 锁文件内容为持有者与过期时间，先写入临时文件再硬链接为锁文件，其它实例不会读到写了一半的内容。
+// 锁文件已过期或内容损坏时把临时文件改名覆盖它，稍后重新读取确认持有者为本实例：
+// 同时接管的实例中只有最后改名的一个能确认成功，其它实例放弃本次运行
+func acquireFileLease(job string, now time.Time, ttl time.Duration) (func(), bool, error) {
+	dir := cronLockDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, false, err
+	}
+	path := fileLeasePath(job)
+	release := func() {
+		if owner, _, err := readFileLease(path); err == nil && owner == leaseOwner {
+			os.Remove(path)
+		}
+	}
+	tmp, err := writeFileLeaseTemp(path, now.Add(ttl))
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.Remove(tmp)
+	err = os.Link(tmp, path) // 与 O_EXCL 相同，锁文件已存在时失败
+	if err == nil {
+		return release, true, nil
+	}
+	if !errors.Is(err, os.ErrExist) {
+		return nil, false, err
+	}
+
+	stale, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil // 刚被持有者释放，等下次调度
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if _, expires, err := parseFileLease(path, stale); err == nil && now.Before(expires) {
+		return nil, false, nil
+	}
+	// 改名前确认锁文件没有被其它实例换掉，缩小与刚取得租约的实例冲突的窗口
+	if cur, err := os.ReadFile(path); err != nil || !bytes.Equal(cur, stale) {
+		return nil, false, nil
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, false, err
+	}
+	time.Sleep(fileLeaseSettle)
+	if owner, _, err := readFileLease(path); err != nil || owner != leaseOwner {
+		return nil, false, err
+	}
+	return release, true, nil
+}
+
+// fileLeaseSettle 接管过期锁文件后等待其它同时接管的实例完成改名，再确认持有者
+const fileLeaseSettle = 100 * time.Millisecond
+
+// renewFileLease 锁文件仍属于本实例时更新其中的过期时间
+func renewFileLease(job string, expires time.Time) (bool, error) {
+	path := fileLeasePath(job)
+	owner, _, err := readFileLease(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if owner != leaseOwner {
+		return false, nil
+	}
+	tmp, err := writeFileLeaseTemp(path, expires)
+	if err != nil {
+		return true, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return true, err
+	}
+	return true, nil
+}
+
+// writeFileLeaseTemp 把本实例的租约写入锁文件旁的临时文件，由调用方改名或链接为锁文件
+func writeFileLeaseTemp(path string, expires time.Time) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(fileLeaseContent(expires))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func fileLeasePath(job string) string {
+	return filepath.Join(cronLockDir(), job+".lock")
+}
+
+func fileLeaseContent(expires time.Time) string {
+	return fmt.Sprintf("owner=%s\nexpires=%d\n", leaseOwner, expires.UnixMilli())
+}
+
+func readFileLease(path string) (owner string, expires time.Time, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return parseFileLease(path, data)
+}
+
+func parseFileLease(path string, data []byte) (owner string, expires time.Time, err error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "owner":
+			owner = value
+		case "expires":
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("invalid expires %q", value)
+			}
+			expires = time.UnixMilli(ms)
+		}
+	}
+	if owner == "" || expires.IsZero() {
+		return "", time.Time{}, fmt.Errorf("incomplete lock file %s", path)
+	}
+	return owner, expires, nil
+}
+
+// writeFileLastStart 没有数据库时把最近一次开始运行的时间写入数据目录，供其它实例判断任务是否已运行
+func writeFileLastStart(job string, t time.Time) error {
+	dir := cronLockDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, job+".last"), []byte(strconv.FormatInt(t.UnixMilli(), 10)), 0644)
+}
+
+func readFileLastStart(job string) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(cronLockDir(), job+".last"))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
@@ -0,0 +1,63 @@
+package followStartAndCron
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+func TestFileLease(t *testing.T) {
+	oldPath, oldOwner := system_config.DbUserPath, leaseOwner
+	system_config.DbUserPath = filepath.Join(t.TempDir(), "nascore.db")
+	t.Cleanup(func() { system_config.DbUserPath, leaseOwner = oldPath, oldOwner })
+
+	now := time.Now()
+	leaseOwner = "a"
+	release, ok, err := acquireFileLease("backup", now, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("first acquire = %v, %v", ok, err)
+	}
+
+	leaseOwner = "b"
+	if _, ok, err := acquireFileLease("backup", now, time.Minute); err != nil || ok {
+		t.Fatalf("acquire of a held lease = %v, %v, want false", ok, err)
+	}
+	if held, _ := renewFileLease("backup", now.Add(time.Minute)); held {
+		t.Fatal("renew by another instance should fail")
+	}
+	release() // 不属于 b，不能删除
+	if owner, _, err := readFileLease(fileLeasePath("backup")); err != nil || owner != "a" {
+		t.Fatalf("owner after foreign release = %q, %v, want a", owner, err)
+	}
+
+	// 过期后被接管
+	later := now.Add(2 * time.Minute)
+	release, ok, err = acquireFileLease("backup", later, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("takeover of an expired lease = %v, %v", ok, err)
+	}
+	if owner, expires, _ := readFileLease(fileLeasePath("backup")); owner != "b" || !expires.Equal(time.UnixMilli(later.Add(time.Minute).UnixMilli())) {
+		t.Fatalf("lease after takeover = %q %v", owner, expires)
+	}
+	leaseOwner = "a"
+	if held, _ := renewFileLease("backup", later); held {
+		t.Fatal("the previous owner should notice the takeover on renew")
+	}
+	leaseOwner = "b"
+	release()
+	if _, err := os.Stat(fileLeasePath("backup")); !os.IsNotExist(err) {
+		t.Fatalf("lock file after release: %v", err)
+	}
+
+	// 内容损坏的锁文件同样被接管
+	os.WriteFile(fileLeasePath("backup"), []byte("garbage"), 0644)
+	if _, ok, err := acquireFileLease("backup", now, time.Minute); err != nil || !ok {
+		t.Fatalf("takeover of a corrupt lease = %v, %v", ok, err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(cronLockDir(), "*.tmp")); len(tmps) != 0 {
+		t.Fatalf("temp files left behind: %v", tmps)
+	}
+}
@@ -2,22 +2,21 @@ package followStartAndCron
 
 import (
 	"database/sql"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/nas-core/nascore/nascore_util/subscription"
-
+	"github.com/nas-core/nascore/nascore_util/exeStart"
 	"github.com/nas-core/nascore/nascore_util/system_config"
 
 	"go.uber.org/zap"
 )
 
 var (
-	isLoopOneSecondrun           int32
-	isCheckingCron               int32
-	lastExecADGuardsGetRulesTime int64
-	lastExecLegoRenewOrGetTime   int64
-	isReloadingNascoreToml       int32
+	isLoopOneSecondrun     int32
+	isCheckingCron         int32
+	isReloadingNascoreToml int32
+	isConfigWatcherStarted int32
 
 	// 这些变量用于跟踪在当前进程生命周期中是否已启动随从启动操作。在无服务器环境中，
 	// 每个请求可能会启动一个新进程，因此理想情况下，如果外部程序需要在每个新实例上启动， 则这些变量应在每个请求时重置或重新评估。
@@ -29,12 +28,6 @@ var (
 	isExtProgramFollowStart  int32
 	// lastRefreshSubscriptionTime int64 // 删除未用变量
 	// isRefreshingSubscription    int32 // 删除未用变量
-
-	// 这些变量用于跟踪在当前进程生命周期中是否已启动随从启动操作。在无服务器环境中，
-	// 每个请求可能会启动一个新进程，因此理想情况下，如果外部程序需要在每个新实例上启动， 则这些变量应在每个请求时重置或重新评估。
-
-	vodLastRefreshSubscriptionTime int64
-	vodIsRefreshingSubscription    int32
 )
 
 var SaveVodSubscriptionFunc func(string) error
@@ -42,6 +35,12 @@ var SaveVodSubscriptionFunc func(string) error
 // 新增全局变量
 var VodSqliteDB *sql.DB
 
+var (
+	initialConfigOnce    sync.Once
+	cronSqliteDBOnce     sync.Once
+	registerServicesOnce sync.Once
+)
+
 // const refreshSubscriptionThrottle = 5 * time.Second // 删除未用常量
 
 // 防止独立部署的情况下，没有请求时自动任务不执行。
@@ -58,6 +57,12 @@ func FollowStartAndCronMainLoop_forMachine(nsCfg *system_config.SysCfg, logger *
 
 // 插入到每个路由前面，方便兼容无状态服务器。
 func FollowStartAndCronMain_forStateless_andForMachine(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	initialConfigOnce.Do(func() {
+		system_config.StoreInitial(nsCfg)
+		// 先于随从启动订阅事件，之后由 applyEventConfig 在配置重载时更新
+		applyEventConfig(nil, system_config.Current(), nil, logger)
+	})
+	nsCfg = system_config.Current() // 本轮使用同一份配置快照
 	// 如果在无服务器模式下，重置随从启动标志以确保外部程序在每个新实例上被检查/启动。
 	if nsCfg.Server.IsRunInServerLess {
 		atomic.StoreInt32(&isRcloneMountFollowStart, 0)
@@ -66,11 +71,24 @@ func FollowStartAndCronMain_forStateless_andForMachine(nsCfg *system_config.SysC
 		atomic.StoreInt32(&isOpenlistFollowStart, 0)
 		atomic.StoreInt32(&isExtProgramFollowStart, 0)
 		atomic.StoreInt32(&isLoopOneSecondrun, 0) // 确保 loopCheckFollowStart 每请求运行一次
-		atomic.StoreInt32(&isCheckingCron, 0)     // 确保 cronFunc 每请求运行一次
+		atomic.StoreInt32(&isCheckingCron, 0)     // 确保 cronFunc 每请求运行一次，任务本身由调度器的运行标记和跨实例租约保证不重复执行
 	}
 	if nsCfg.Server.IsRunInServerLess {
 		CheckAllExtensionStatusOnce(nsCfg)
+	} else if atomic.CompareAndSwapInt32(&isConfigWatcherStarted, 0, 1) {
+		startConfigWatcher(nsCfg, logger) // 无服务器模式每个请求都会重新读取配置，不需要监听
 	}
+	cronSqliteDBOnce.Do(func() {
+		if CronSqliteDB != nil { // 主程序已经设置
+			return
+		}
+		if err := OpenCronSqliteDB(system_config.DbUserPath); err != nil {
+			logger.Warnf("[cron] open %s for job history err: %v, using the vod database instead", system_config.DbUserPath, err)
+		}
+	})
+	registerServicesOnce.Do(func() {
+		exeStart.RegisterThirdPartyServices(nsCfg, logger) // 之后由 applyServicesRegistration 在配置重载时更新
+	})
 	if atomic.LoadInt32(&isLoopOneSecondrun) == 0 { // 避免循环启动
 		if nsCfg.Server.IsRunInServerLess {
 			loopCheckFollowStart(nsCfg, logger) // 同步执行，无睡眠
@@ -97,64 +115,27 @@ func cronFunc(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
 	}
 	defer atomic.StoreInt32(&isCheckingCron, 0)
 
-	nowTimeInt64 := time.Now().Unix() // 秒
+	scheduler.tick(system_config.CurrentOr(nsCfg), logger, time.Now())
 
-	if nsCfg.ThirdPartyExt.AdGuard.AutoUpdateRulesEnable {
-		if nowTimeInt64-lastExecADGuardsGetRulesTime > int64(nsCfg.ThirdPartyExt.AdGuard.AutoUpdateRulesInterval*3600) {
-			logger.Debug("[adg] Start ADGuards update Rules as scheduled.")
-			execADGuardsGetRules(nsCfg, logger)
-			atomic.StoreInt64(&lastExecADGuardsGetRulesTime, nowTimeInt64)
-		}
-	}
-
-	if nsCfg.ThirdPartyExt.AcmeLego.IsLegoAutoRenew {
-		if nowTimeInt64-lastExecLegoRenewOrGetTime > int64(nsCfg.ThirdPartyExt.AcmeLego.AutoUpdateCheckInterval*3600) {
-			logger.Debug("[lego] Start lego as scheduled.")
-			execLegoRenewOrGet(nsCfg, logger)
-			atomic.StoreInt64(&lastExecLegoRenewOrGetTime, nowTimeInt64)
-		}
-	}
-
-	// 热重载配置。仅在未重新加载时尝试。
-	if atomic.CompareAndSwapInt32(&isReloadingNascoreToml, 0, 1) {
-		reloadNascoreToml(nsCfg)
+	// 热重载配置。监听文件生效时由监听触发，否则在文件变化后重新加载。仅在未重新加载时尝试。
+	if !configWatching.Load() && atomic.CompareAndSwapInt32(&isReloadingNascoreToml, 0, 1) {
+		reloadNascoreTomlIfModified(nsCfg, logger)
 		atomic.StoreInt32(&isReloadingNascoreToml, 0)
 	}
-
-	// 定时刷新 VOD 订阅
-	vodSub := nsCfg.NascoreExt.Vod.VodSubscription
-	currentTime := time.Now().Unix()
-	if len(vodSub.Urls) > 0 && (currentTime-atomic.LoadInt64(&vodLastRefreshSubscriptionTime) > int64(vodSub.IntervalHour)*3600 || atomic.LoadInt64(&vodLastRefreshSubscriptionTime) == 0) {
-		if atomic.CompareAndSwapInt32(&vodIsRefreshingSubscription, 0, 1) {
-			go func() {
-				defer atomic.StoreInt32(&vodIsRefreshingSubscription, 0)
-				err := subscription.RefreshSubscriptionAndSaveToDB(VodSqliteDB, vodSub.Urls, nsCfg.ThirdPartyExt.GitHubDownloadMirror, logger)
-				if err != nil {
-					logger.Errorf("[vod] refresh subscription error: %v", err)
-				} else {
-					logger.Debug("[vod] refresh subscription success")
-				}
-				atomic.StoreInt64(&vodLastRefreshSubscriptionTime, currentTime)
-			}()
-		}
-	}
 }
 
 // 手动触发 VOD 订阅刷新
 func RefreshVodSubscriptionNow(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
-	vodSub := nsCfg.NascoreExt.Vod.VodSubscription
-	if len(vodSub.Urls) == 0 {
+	if len(nsCfg.NascoreExt.Vod.VodSubscription.Urls) == 0 {
 		logger.Warn("[vod] No subscription urls configured")
 		return
 	}
 	logger.Debug("[vod] Manual trigger: Start refreshing subscription...")
-	err := subscription.RefreshSubscriptionAndSaveToDB(VodSqliteDB, vodSub.Urls, nsCfg.ThirdPartyExt.GitHubDownloadMirror, logger)
-	if err != nil {
+	if err := scheduler.runNow(nsCfg, logger, JobVodSubscription, true); err != nil {
 		logger.Errorf("[vod] Manual refresh subscription error: %v", err)
 	} else {
 		logger.Debug("[vod] Manual refresh subscription success")
 	}
-	atomic.StoreInt64(&vodLastRefreshSubscriptionTime, time.Now().Unix())
 }
 
 func loopCheckFollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
@@ -167,49 +148,8 @@ func loopCheckFollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger
 	// 在非无服务器模式下，此循环持续运行。
 	// 在无服务器模式下，它每个请求周期运行一次。
 	for {
-		if nsCfg.ThirdPartyExt.Rclone.AutoMountEnable && atomic.CompareAndSwapInt32(&isRcloneMountFollowStart, 0, 1) {
-			logger.Debug("Starting Rclone AutoMount FollowStart.")
-			if nsCfg.Server.IsRunInServerLess {
-				RcloneFollowStart(nsCfg, logger)
-			} else {
-				go RcloneFollowStart(nsCfg, logger)
-			}
-		}
-		if nsCfg.ThirdPartyExt.DdnsGO.AutoStartEnable && atomic.CompareAndSwapInt32(&isDdnsSGOFollowStart, 0, 1) {
-			logger.Debug("Starting DdnsSGOFollowStart.")
-			if nsCfg.Server.IsRunInServerLess {
-				DdnsSGOFollowStart(nsCfg, logger)
-			} else {
-				go DdnsSGOFollowStart(nsCfg, logger)
-			}
-		}
-		// caddy2
-		if nsCfg.ThirdPartyExt.Caddy2.AutoStartEnable && atomic.CompareAndSwapInt32(&isCaddy2FollowStart, 0, 1) {
-			logger.Debug("Starting Caddy2FollowStart.")
-			if nsCfg.Server.IsRunInServerLess {
-				Caddy2FollowStart(nsCfg, logger)
-			} else {
-				go Caddy2FollowStart(nsCfg, logger)
-			}
-		}
-		// openlost
-		if nsCfg.ThirdPartyExt.Openlist.AutoStartEnable && atomic.CompareAndSwapInt32(&isOpenlistFollowStart, 0, 1) {
-			logger.Debug("Starting OpenlistFollowStart.")
-			if nsCfg.Server.IsRunInServerLess {
-				OpenlistFollowStart(nsCfg, logger)
-			} else {
-				go OpenlistFollowStart(nsCfg, logger)
-			}
-		}
-		// 扩展程序
-		if atomic.CompareAndSwapInt32(&isExtProgramFollowStart, 0, 1) {
-			logger.Debug("Starting nascore_vod")
-			if nsCfg.Server.IsRunInServerLess {
-				Nascore_extended_followStart(nsCfg, logger)
-			} else {
-				go Nascore_extended_followStart(nsCfg, logger)
-			}
-		}
+		nsCfg := system_config.CurrentOr(nsCfg) // 每轮取最新的配置快照
+		followStartInOrder(nsCfg, logger)
 		if !nsCfg.Server.IsRunInServerLess {
 			CheckAllExtensionStatusOnce(nsCfg)
 		}
@@ -221,3 +161,45 @@ func loopCheckFollowStart(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger
 		}
 	}
 }
+
+// lastStartOrderErr 上一次依赖解析的错误，避免每秒重复输出同样的日志
+var lastStartOrderErr string
+
+// followStartInOrder 按依赖关系依次启动尚未启动的随从程序。Requires 未满足的程序本轮跳过，下一轮再检查
+func followStartInOrder(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	units := followStartUnits(nsCfg)
+	ordered, err := resolveStartOrder(units)
+	errStr := ""
+	if err != nil {
+		errStr = err.Error()
+	}
+	if errStr != lastStartOrderErr {
+		lastStartOrderErr = errStr
+		if err != nil {
+			logger.Errorf("[followStart] %v, these services will not be started", err)
+		}
+	}
+
+	byName := make(map[string]startUnit, len(units))
+	for _, u := range units {
+		byName[u.Name] = u
+	}
+	for _, u := range ordered {
+		if !u.Enabled || atomic.LoadInt32(u.Started) != 0 {
+			continue
+		}
+		if reason := unmetRequirement(u, byName, logger); reason != "" {
+			logger.Debugf("[followStart] %s waiting: %s", u.Name, reason)
+			continue
+		}
+		if !atomic.CompareAndSwapInt32(u.Started, 0, 1) {
+			continue
+		}
+		logger.Debugf("[followStart] Starting %s.", u.Name)
+		if u.Async && !nsCfg.Server.IsRunInServerLess {
+			go u.Start(nsCfg, logger)
+		} else if err := u.Start(nsCfg, logger); err != nil {
+			logger.Warnf("[followStart] start %s err: %v", u.Name, err)
+		}
+	}
+}
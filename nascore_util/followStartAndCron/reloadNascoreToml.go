@@ -1,16 +1,148 @@
 package followStartAndCron
 
 import (
+	"errors"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/nas-core/nascore/nascore_util/eventbus"
 	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// configReloadDebounce 编辑器保存时常常连续产生多个事件，最后一个事件之后等待这么久再重载
+const configReloadDebounce = 500 * time.Millisecond
+
+var (
+	reloadMu      sync.Mutex
+	lastReloadErr string    // 上次重载失败的错误，相同的错误只记录、发布一次
+	lastConfigMod fileStamp // 上次加载时配置文件的状态，轮询时用来判断文件是否变化
+
+	configWatching    atomic.Bool // fsnotify 监听已生效，不再轮询
+	configWatcherOnce sync.Once
 )
 
-func reloadNascoreToml(nsCfg *system_config.SysCfg) {
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func configFileStamp() fileStamp {
+	fi, err := os.Stat(system_config.ConfigFilePath)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{fi.ModTime(), fi.Size()}
+}
+
+// reloadNascoreToml 重新加载配置文件，有变化时以新快照发布（system_config.Store），再交给各部分的处理函数。
+// 加载失败或有致命问题时保留当前配置。nsCfg 为尚未发布过配置时使用的当前配置
+func reloadNascoreToml(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	lastConfigMod = configFileStamp()
 	tmpNsCfg, err := system_config.LoadConfig(system_config.ConfigFilePath)
 	if err == nil {
-		*nsCfg = *tmpNsCfg
-	} else {
+		lastReloadErr = ""
+		oldNsCfg := system_config.CurrentOr(nsCfg)
+		diff := system_config.Diff(oldNsCfg, tmpNsCfg)
+		if len(diff) == 0 {
+			return
+		}
+		system_config.Store(tmpNsCfg)
+		for _, issue := range tmpNsCfg.Validate() { // 致命问题已在 LoadConfig 中拒绝，这里只有警告
+			log.Println("config", issue)
+		}
+		logger.Debugf("[config] reloaded, changed: %s", strings.Join(diff.Paths(), ", "))
+		eventbus.Publish(eventbus.TypeConfigReloaded, "config", map[string]any{"path": system_config.ConfigFilePath, "changed": diff.Paths()})
+		applyConfigChanges(oldNsCfg, tmpNsCfg, diff, logger)
+		return
+	}
+	if err.Error() != lastReloadErr {
 		log.Println("hot reload nascore toml file  err", err.Error())
+		lastReloadErr = err.Error()
+		data := map[string]any{"path": system_config.ConfigFilePath, "error": err.Error()}
+		var verr *system_config.ValidationError
+		if errors.As(err, &verr) {
+			data["issues"] = verr.Issues.Fatal()
+		}
+		eventbus.Publish(eventbus.TypeConfigReloadFailed, "config", data)
+	}
+}
+
+// reloadNascoreTomlIfModified 无法监听文件时的轮询方式，只在修改时间或大小变化时重新加载
+func reloadNascoreTomlIfModified(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	reloadMu.Lock()
+	modified := configFileStamp() != lastConfigMod
+	reloadMu.Unlock()
+	if modified {
+		reloadNascoreToml(nsCfg, logger)
+	}
+}
+
+// startConfigWatcher 监听配置文件所在的目录并在变化后防抖重载。很多编辑器保存时先写临时文件再改名，
+// 直接监听文件本身会在第一次保存后失效。监听失败时退回轮询
+func startConfigWatcher(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	configWatcherOnce.Do(func() {
+		if system_config.ConfigFilePath == "" {
+			return
+		}
+		path, err := filepath.Abs(system_config.ConfigFilePath)
+		if err != nil {
+			logger.Warnf("[config] watch %s err: %v, polling instead", system_config.ConfigFilePath, err)
+			return
+		}
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			logger.Warnf("[config] create file watcher err: %v, polling instead", err)
+			return
+		}
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			watcher.Close()
+			logger.Warnf("[config] watch %s err: %v, polling instead", filepath.Dir(path), err)
+			return
+		}
+		reloadMu.Lock()
+		lastConfigMod = configFileStamp()
+		reloadMu.Unlock()
+		configWatching.Store(true)
+		logger.Debugf("[config] watching %s", path)
+		go watchConfigEvents(watcher, path, nsCfg, logger)
+	})
+}
+
+func watchConfigEvents(watcher *fsnotify.Watcher, path string, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	defer func() {
+		watcher.Close()
+		configWatching.Store(false) // 监听意外结束，由 cronFunc 轮询接替
+	}()
+	var timer *time.Timer
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != path || ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(configReloadDebounce, func() { reloadNascoreToml(nsCfg, logger) })
+			} else {
+				timer.Reset(configReloadDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warnf("[config] file watcher err: %v", err)
+		}
 	}
 }
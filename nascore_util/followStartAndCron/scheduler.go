@@ -0,0 +1,495 @@
+package followStartAndCron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/cronspec"
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+	"github.com/nas-core/nascore/nascore_util/secretmask"
+	"github.com/nas-core/nascore/nascore_util/subscription"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	JobAdGuardRules    = "adguard-rules"
+	JobLegoRenew       = "lego-renew"
+	JobRcloneRemount   = "rclone-remount"
+	JobVodSubscription = "vod-subscription"
+)
+
+// cronJobState 任务在当前进程中的调度状态，lastRun 与 paused 在首次调度时从数据库恢复
+type cronJobState struct {
+	job      Job
+	builtin  bool
+	specKey  string // spec 与时区，变化时重新解析
+	schedule cronspec.Schedule
+	lastRun  time.Time
+	loaded   bool // 是否已从数据库读取过 lastRun 与 paused
+	paused   bool
+	next     time.Time
+	running  bool
+	specErr  string
+}
+
+// cronScheduler 按 cron 表达式调度计划任务，由 cronFunc 每秒驱动一次
+type cronScheduler struct {
+	mu    sync.Mutex
+	order []string
+	state map[string]*cronJobState
+}
+
+var scheduler = &cronScheduler{state: make(map[string]*cronJobState)}
+
+func init() {
+	scheduler.register(FuncJob{
+		JobName: JobAdGuardRules,
+		Spec: func(nsCfg *system_config.SysCfg) string {
+			adg := nsCfg.ThirdPartyExt.AdGuard
+			if !adg.AutoUpdateRulesEnable {
+				return ""
+			}
+			return cronSpecOrInterval(adg.AutoUpdateRulesCron, adg.AutoUpdateRulesInterval)
+		},
+		TimeoutSec: func(nsCfg *system_config.SysCfg) int { return nsCfg.ThirdPartyExt.AdGuard.JobTimeoutSec },
+		Fn: func(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+			logger.Debug("[adg] Start ADGuards update Rules.")
+			err := execADGuardsGetRules(ctx, nsCfg, logger)
+			if err != nil {
+				return "", err
+			}
+			return "rules saved to " + nsCfg.ThirdPartyExt.AdGuard.Upstream_dns_file, nil
+		},
+	}, true)
+	scheduler.register(FuncJob{
+		JobName: JobLegoRenew,
+		Spec: func(nsCfg *system_config.SysCfg) string {
+			lego := nsCfg.ThirdPartyExt.AcmeLego
+			if !lego.IsLegoAutoRenew {
+				return ""
+			}
+			return cronSpecOrInterval(lego.AutoUpdateCheckCron, lego.AutoUpdateCheckInterval)
+		},
+		TimeoutSec: func(nsCfg *system_config.SysCfg) int { return nsCfg.ThirdPartyExt.AcmeLego.JobTimeoutSec },
+		Fn: func(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+			logger.Debug("[lego] Start lego.")
+			return execLegoRenewOrGet(ctx, nsCfg, logger)
+		},
+	}, true)
+	scheduler.register(FuncJob{
+		JobName: JobRcloneRemount,
+		Spec: func(nsCfg *system_config.SysCfg) string {
+			rclone := nsCfg.ThirdPartyExt.Rclone
+			if !rclone.AutoMountEnable {
+				return ""
+			}
+			return rclone.AutoReMountCron
+		},
+		TimeoutSec: func(nsCfg *system_config.SysCfg) int { return nsCfg.ThirdPartyExt.Rclone.JobTimeoutSec },
+		Fn: func(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+			logger.Debug("[rclone] Start rclone remount.")
+			return exeRcloneAutoMount(ctx, nsCfg, logger)
+		},
+	}, true)
+	scheduler.register(FuncJob{
+		JobName: JobVodSubscription,
+		Spec: func(nsCfg *system_config.SysCfg) string {
+			vodSub := nsCfg.NascoreExt.Vod.VodSubscription
+			if len(vodSub.Urls) == 0 {
+				return ""
+			}
+			return cronSpecOrInterval(vodSub.Cron, vodSub.IntervalHour)
+		},
+		TimeoutSec: func(nsCfg *system_config.SysCfg) int { return nsCfg.NascoreExt.Vod.VodSubscription.JobTimeoutSec },
+		Fn: func(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+			vodSub := nsCfg.NascoreExt.Vod.VodSubscription
+			if len(vodSub.Urls) == 0 {
+				return "", fmt.Errorf("no subscription urls configured")
+			}
+			if VodSqliteDB == nil {
+				return "", fmt.Errorf("vod database is not initialized")
+			}
+			err := subscription.RefreshSubscriptionAndSaveToDBContext(ctx, VodSqliteDB, vodSub.Urls, nsCfg.ThirdPartyExt.GitHubDownloadMirror, logger)
+			if err != nil {
+				logger.Errorf("[vod] refresh subscription error: %v", err)
+				return "", err
+			}
+			logger.Debug("[vod] refresh subscription success")
+			return "refreshed " + strings.Join(vodSub.Urls, ", "), nil
+		},
+	}, true)
+}
+
+// cronSpecOrInterval 优先使用 cron 表达式，否则把按小时配置的间隔转换为 @every
+func cronSpecOrInterval(spec string, intervalHour int) string {
+	if spec != "" {
+		return spec
+	}
+	if intervalHour <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("@every %dh", intervalHour)
+}
+
+func (s *cronScheduler) register(job Job, builtin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.state[job.Name()]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, job.Name())
+	}
+	s.order = append(s.order, job.Name())
+	s.state[job.Name()] = &cronJobState{job: job, builtin: builtin}
+	return nil
+}
+
+func (s *cronScheduler) unregister(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.state[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if st.builtin {
+		return ErrJobBuiltin
+	}
+	delete(s.state, name)
+	for i, n := range s.order {
+		if n == name {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// jobs 返回当前注册的任务快照，调度时不持有锁
+func (s *cronScheduler) jobs() []*cronJobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]*cronJobState, 0, len(s.order))
+	for _, name := range s.order {
+		states = append(states, s.state[name])
+	}
+	return states
+}
+
+// loadLocked 首次调度时从数据库恢复最近一次运行时间与暂停状态
+func (s *cronScheduler) loadLocked(name string, st *cronJobState, logger *zap.SugaredLogger) {
+	if st.loaded || !jobHistoryAvailable() {
+		return
+	}
+	st.loaded = true
+	if last, err := lastJobStart(name); err != nil {
+		logger.Warnf("[cron] read run history of %s err: %v", name, err)
+	} else if last.After(st.lastRun) {
+		st.lastRun = last
+		st.specKey = "" // 按恢复的运行时间重新计算下一次运行
+	}
+	if paused, err := isJobPaused(name); err != nil {
+		logger.Warnf("[cron] read pause state of %s err: %v", name, err)
+	} else {
+		st.paused = paused
+	}
+}
+
+// updateScheduleLocked 在 spec 或时区变化时重新解析，并推算下一次运行时间
+func (s *cronScheduler) updateScheduleLocked(name string, st *cronJobState, spec string, loc *time.Location, now time.Time, logger *zap.SugaredLogger) {
+	if spec == "" {
+		st.specKey, st.schedule, st.next, st.specErr = "", nil, time.Time{}, ""
+		return
+	}
+	key := spec + "|" + loc.String()
+	if key == st.specKey {
+		return
+	}
+	st.specKey = key
+	schedule, err := cronspec.Parse(spec, loc)
+	if err != nil {
+		st.schedule, st.next = nil, time.Time{}
+		if st.specErr != err.Error() {
+			st.specErr = err.Error()
+			logger.Errorf("[cron] job %s disabled: %v", name, err)
+		}
+		return
+	}
+	st.schedule, st.specErr = schedule, ""
+	_, isEvery := schedule.(cronspec.EverySchedule)
+	switch {
+	case !st.lastRun.IsZero():
+		st.next = schedule.Next(st.lastRun)
+	case isEvery:
+		st.next = now
+	default:
+		st.next = schedule.Next(now)
+	}
+	logger.Debugf("[cron] job %s scheduled with %q, next run at %s", name, spec, st.next.Format(time.DateTime))
+}
+
+// tick 执行所有到期的任务。下一次运行时间从最近一次运行记录推算，重启或冷启动后不会重复执行；
+// 从未运行过的 @every 任务立即执行，cron 表达式任务则等待下一个匹配的时间点。
+// 独立部署时每个任务在各自的 goroutine 中运行，一个任务耗时较长不会推迟其它任务
+func (s *cronScheduler) tick(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, now time.Time) {
+	loc := cronLocation(nsCfg.Server.CronTimeZone, logger)
+	for _, st := range s.jobs() {
+		name := st.job.Name()
+		spec := st.job.Schedule(nsCfg)
+		s.mu.Lock()
+		s.loadLocked(name, st, logger)
+		s.updateScheduleLocked(name, st, spec, loc, now, logger)
+		if st.schedule == nil || st.paused || st.running || now.Before(st.next) {
+			s.mu.Unlock()
+			continue
+		}
+		st.running = true
+		s.mu.Unlock()
+
+		if nsCfg.Server.IsRunInServerLess {
+			s.run(st, nsCfg, logger, now, true)
+		} else {
+			go s.run(st, nsCfg, logger, now, true)
+		}
+	}
+}
+
+// runNow 手动运行任务，wait 为 true 时等待运行结束
+func (s *cronScheduler) runNow(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, name string, wait bool) error {
+	s.mu.Lock()
+	st, ok := s.state[name]
+	if !ok {
+		s.mu.Unlock()
+		return ErrJobNotFound
+	}
+	if st.running {
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+	st.running = true
+	s.mu.Unlock()
+
+	logger.Debugf("[cron] job %s triggered manually", name)
+	if wait {
+		return s.run(st, nsCfg, logger, time.Now(), false)
+	}
+	go s.run(st, nsCfg, logger, time.Now(), false)
+	return nil
+}
+
+// run 运行任务并记录结果。运行前获取跨实例的租约，scheduled 为 true 时还会检查其它实例是否已在本周期运行过。
+// 任务在超时后未返回时不再等待，但在它真正返回前本实例不会再次运行它，租约也一直续期、不会释放。
+// 手动运行（scheduled 为 false）只写入运行记录，不更新 lastRun 与下一次运行时间
+func (s *cronScheduler) run(st *cronJobState, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, startedAt time.Time, scheduled bool) error {
+	name := st.job.Name()
+	timeout := jobTimeout(st.job, nsCfg)
+	release, ok, err := acquireJobLease(name, timeout+jobCancelGrace+jobLeaseMargin, logger)
+	if err != nil {
+		logger.Warnf("[cron] acquire lease of %s err: %v, running without lease", name, err)
+		release, ok = func() {}, true
+	}
+	if !ok {
+		s.setRunning(st, false)
+		if scheduled {
+			logger.Debugf("[cron] job %s is running in another instance, skipped", name)
+			return nil
+		}
+		return ErrJobRunning
+	}
+	if scheduled && s.ranElsewhere(st, name, startedAt, logger) {
+		release()
+		s.setRunning(st, false)
+		return nil
+	}
+
+	var exited <-chan struct{}
+	err = recordJobRun(name, startedAt, !scheduled, logger, func() (string, error) {
+		var output string
+		var err error
+		output, exited, err = runWithTimeout(st.job, nsCfg, logger, timeout)
+		return output, err
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-exited:
+		st.running = false
+		release()
+	default:
+		logger.Errorf("[cron] job %s did not return after cancellation, it will not run again until it does", name)
+		go func() {
+			<-exited
+			release()
+			s.setRunning(st, false)
+			logger.Warnf("[cron] abandoned job %s finally returned", name)
+		}()
+	}
+	if !scheduled {
+		return err // 手动运行不影响之后的调度
+	}
+	st.lastRun = startedAt
+	if st.schedule == nil {
+		return err
+	}
+	// 执行时间超过一个周期时从当前时间重新计算，避免连续补跑
+	st.next = st.schedule.Next(startedAt)
+	if now := time.Now(); st.next.Before(now) {
+		st.next = st.schedule.Next(now)
+	}
+	return err
+}
+
+func (s *cronScheduler) setRunning(st *cronJobState, running bool) {
+	s.mu.Lock()
+	st.running = running
+	s.mu.Unlock()
+}
+
+// ranElsewhere 取得租约后重新读取运行记录，其它实例已在本周期运行过时更新本地的调度时间并返回 true
+func (s *cronScheduler) ranElsewhere(st *cronJobState, name string, now time.Time, logger *zap.SugaredLogger) bool {
+	last, err := lastJobStart(name)
+	if err != nil {
+		logger.Warnf("[cron] read run history of %s err: %v", name, err)
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !last.After(st.lastRun) || st.schedule == nil {
+		return false
+	}
+	st.lastRun = last
+	if next := st.schedule.Next(last); now.Before(next) {
+		st.next = next
+		logger.Debugf("[cron] job %s already ran at %s in another instance, next run at %s", name, last.Format(time.DateTime), next.Format(time.DateTime))
+		return true
+	}
+	return false
+}
+
+// runWithTimeout 在带超时的 ctx 中运行任务。超时后再等待 jobCancelGrace，任务仍未返回则放弃等待，
+// exited 在任务真正返回后关闭
+func runWithTimeout(job Job, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, timeout time.Duration) (string, <-chan struct{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	done := make(chan struct{})
+	var output string
+	var err error
+	go func() {
+		defer close(done)
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		output, err = job.Run(ctx, nsCfg, logger)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		grace := time.NewTimer(jobCancelGrace)
+		defer grace.Stop()
+		select {
+		case <-done:
+		case <-grace.C:
+			return "", done, fmt.Errorf("%w after %s", ErrJobTimeout, timeout)
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %v", ErrJobTimeout, timeout, err)
+	}
+	return output, done, err
+}
+
+func (s *cronScheduler) setPaused(name string, paused bool) error {
+	s.mu.Lock()
+	st, ok := s.state[name]
+	if !ok {
+		s.mu.Unlock()
+		return ErrJobNotFound
+	}
+	st.paused = paused
+	s.mu.Unlock()
+	return saveJobPaused(name, paused)
+}
+
+func (s *cronScheduler) list(nsCfg *system_config.SysCfg) []JobInfo {
+	states := s.jobs()
+	infos := make([]JobInfo, 0, len(states))
+	for _, st := range states {
+		spec := st.job.Schedule(nsCfg)
+		s.mu.Lock()
+		info := JobInfo{
+			Name:      st.job.Name(),
+			Schedule:  spec,
+			Builtin:   st.builtin,
+			Paused:    st.paused,
+			Running:   st.running,
+			LastRun:   st.lastRun,
+			SpecError: st.specErr,
+		}
+		if st.schedule != nil && !st.paused {
+			info.NextRun = st.next
+		}
+		s.mu.Unlock()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// recordJobRun 执行 fn 并把开始、结束时间、结果与输出写入运行记录，数据库出错只记录日志
+func recordJobRun(name string, startedAt time.Time, manual bool, logger *zap.SugaredLogger, fn func() (string, error)) error {
+	id, err := recordJobStart(name, startedAt, manual)
+	if err != nil {
+		logger.Warnf("[cron] record start of %s err: %v", name, err)
+	}
+	output, runErr := fn()
+	output, runErr = secretmask.String(output), secretmask.Error(runErr) // 运行记录与事件会通过 API、webhook 对外发送
+	if err := recordJobFinish(id, name, startedAt, output, runErr); err != nil {
+		logger.Warnf("[cron] record result of %s err: %v", name, err)
+	}
+	data := map[string]any{"job": name, "manual": manual, "started_at": startedAt, "duration_ms": time.Since(startedAt).Milliseconds()}
+	switch {
+	case errors.Is(runErr, ErrJobTimeout):
+		logger.Errorf("[cron] job %s timed out: %v", name, runErr)
+		data["error"] = runErr.Error()
+		eventbus.Publish(eventbus.TypeJobTimeout, "scheduler", data)
+	case runErr != nil:
+		logger.Warnf("[cron] job %s failed: %v", name, runErr)
+		data["error"] = runErr.Error()
+		eventbus.Publish(eventbus.TypeJobFailed, "scheduler", data)
+	default:
+		eventbus.Publish(eventbus.TypeJobSucceeded, "scheduler", data)
+	}
+	return runErr
+}
+
+var (
+	cronLocMu      sync.Mutex
+	cronLocName    string
+	cronLocCurrent = time.Local
+)
+
+// cronLocation 返回配置的计划任务时区，无效时使用系统时区
+func cronLocation(name string, logger *zap.SugaredLogger) *time.Location {
+	cronLocMu.Lock()
+	defer cronLocMu.Unlock()
+	if name == cronLocName {
+		return cronLocCurrent
+	}
+	cronLocName = name
+	cronLocCurrent = time.Local
+	if name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			logger.Errorf("[cron] invalid CronTimeZone %q, using system time zone: %v", name, err)
+		} else {
+			cronLocCurrent = loc
+		}
+	}
+	return cronLocCurrent
+}
@@ -0,0 +1,51 @@
+package followStartAndCron
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/cronspec"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+func TestManualRunKeepsSchedule(t *testing.T) {
+	oldPath := system_config.DbUserPath
+	system_config.DbUserPath = filepath.Join(t.TempDir(), "nascore.db") // 没有数据库，使用锁文件
+	t.Cleanup(func() { system_config.DbUserPath = oldPath })
+
+	schedule, _ := cronspec.Parse("0 3 * * *", time.UTC)
+	next := time.Date(2030, 1, 2, 3, 0, 0, 0, time.UTC)
+	runs := 0
+	st := &cronJobState{
+		job: FuncJob{JobName: "manual-test", Fn: func(ctx context.Context, nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) (string, error) {
+			runs++
+			return "", nil
+		}},
+		schedule: schedule,
+		next:     next,
+	}
+	s := &cronScheduler{state: map[string]*cronJobState{"manual-test": st}}
+	cfg, logger := system_config.NewDefaultConfig(), zap.NewNop().Sugar()
+
+	started := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.run(st, cfg, logger, started, false); err != nil || runs != 1 {
+		t.Fatalf("manual run = %v, runs %d", err, runs)
+	}
+	if !st.lastRun.IsZero() || !st.next.Equal(next) {
+		t.Fatalf("manual run changed the schedule: lastRun %v next %v", st.lastRun, st.next)
+	}
+	if last, _ := lastJobStart("manual-test"); !last.IsZero() {
+		t.Fatalf("manual run recorded as the last scheduled start %v", last)
+	}
+
+	if err := s.run(st, cfg, logger, started, true); err != nil || runs != 2 {
+		t.Fatalf("scheduled run = %v, runs %d", err, runs)
+	}
+	if !st.lastRun.Equal(started) {
+		t.Fatalf("scheduled run lastRun = %v, want %v", st.lastRun, started)
+	}
+}
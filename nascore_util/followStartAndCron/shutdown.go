@@ -0,0 +1,32 @@
+package followStartAndCron
+
+import (
+	"context"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/exeStart"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+// shutdownUnmountTimeout 退出时卸载 rclone 的最长等待时间
+const shutdownUnmountTimeout = 30 * time.Second
+
+// Shutdown 在 nascore 退出前调用：按启动顺序的逆序停止所有托管的第三方程序，
+// 每个程序先收到 SIGTERM，超过 StopGraceSec 后被 SIGKILL，最后执行一次 AutoUnMountCommand 卸载 rclone 挂载
+func Shutdown(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	nsCfg = system_config.CurrentOr(nsCfg) // 按最新配置卸载
+	logger.Debug("[shutdown] stopping managed services")
+	sv := exeStart.DefaultSupervisor(logger)
+	if !nsCfg.ThirdPartyExt.Rclone.AutoMountEnable {
+		sv.StopAll()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownUnmountTimeout)
+	defer cancel()
+	stopRcloneThenUnmount(ctx, nsCfg, logger, func() {
+		sv.StopAll()
+		logger.Debug("[shutdown] unmounting rclone")
+	})
+}
@@ -0,0 +1,162 @@
+package followStartAndCron
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nas-core/nascore/nascore_util/exeStart"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	unitRclone     = "rclone"
+	unitExtensions = "extensions"
+)
+
+// startUnit loopCheckFollowStart 中的一个随从启动项
+type startUnit struct {
+	Name     string
+	Enabled  bool
+	After    []string // 仅约束顺序
+	Requires []string // 服务名或 mount: / file: 条件，未满足时本轮跳过
+	Async    bool     // 启动函数会长时间阻塞，需要放到独立的 goroutine 中
+	Started  *int32   // 本进程内是否已启动
+	Start    func(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) error
+}
+
+// followStartUnits 返回所有随从启动项，依赖关系来自各服务的 Supervise 配置
+func followStartUnits(nsCfg *system_config.SysCfg) []startUnit {
+	ext := nsCfg.ThirdPartyExt
+	return []startUnit{
+		{Name: unitRclone, Enabled: ext.Rclone.AutoMountEnable, Started: &isRcloneMountFollowStart, Start: RcloneFollowStart},
+		{Name: exeStart.ServiceDDNSGo, Enabled: ext.DdnsGO.AutoStartEnable, After: ext.DdnsGO.Supervise.After, Requires: ext.DdnsGO.Supervise.Requires, Started: &isDdnsSGOFollowStart, Start: DdnsSGOFollowStart},
+		{Name: exeStart.ServiceCaddy2, Enabled: ext.Caddy2.AutoStartEnable, After: ext.Caddy2.Supervise.After, Requires: ext.Caddy2.Supervise.Requires, Started: &isCaddy2FollowStart, Start: Caddy2FollowStart},
+		{Name: exeStart.ServiceOpenlist, Enabled: ext.Openlist.AutoStartEnable, After: ext.Openlist.Supervise.After, Requires: ext.Openlist.Supervise.Requires, Started: &isOpenlistFollowStart, Start: OpenlistFollowStart},
+		{Name: unitExtensions, Enabled: true, Async: true, Started: &isExtProgramFollowStart, Start: Nascore_extended_followStart},
+	}
+}
+
+// isConditionDep 依赖项是否为条件而不是服务名
+func isConditionDep(dep string) bool {
+	return strings.HasPrefix(dep, "mount:") || strings.HasPrefix(dep, "file:")
+}
+
+// resolveStartOrder 将启动项按依赖关系拓扑排序（Kahn 算法），同层按原有顺序。
+// 存在环时返回错误，环上的启动项不会出现在结果中
+func resolveStartOrder(units []startUnit) ([]startUnit, error) {
+	index := make(map[string]int, len(units))
+	for i, u := range units {
+		index[u.Name] = i
+	}
+	inDegree := make([]int, len(units))
+	dependents := make([][]int, len(units))
+	for i, u := range units {
+		for _, dep := range append(append([]string{}, u.After...), u.Requires...) {
+			if isConditionDep(dep) {
+				continue
+			}
+			j, ok := index[dep]
+			if !ok || j == i {
+				continue
+			}
+			inDegree[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var queue, orderIdx []int
+	for i := range units {
+		if inDegree[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		sort.Ints(queue)
+		i := queue[0]
+		queue = queue[1:]
+		orderIdx = append(orderIdx, i)
+		for _, d := range dependents[i] {
+			inDegree[d]--
+			if inDegree[d] == 0 {
+				queue = append(queue, d)
+			}
+		}
+	}
+
+	ordered := make([]startUnit, 0, len(units))
+	for _, i := range orderIdx {
+		ordered = append(ordered, units[i])
+	}
+	if len(ordered) != len(units) {
+		var cycle []string
+		for i, u := range units {
+			if inDegree[i] > 0 {
+				cycle = append(cycle, u.Name)
+			}
+		}
+		return ordered, fmt.Errorf("dependency cycle between: %s", strings.Join(cycle, ", "))
+	}
+	return ordered, nil
+}
+
+// unmetRequirement 返回第一个未满足的 Requires 依赖及原因，全部满足时返回空字符串
+func unmetRequirement(u startUnit, units map[string]startUnit, logger *zap.SugaredLogger) string {
+	for _, dep := range u.Requires {
+		if path, ok := strings.CutPrefix(dep, "mount:"); ok {
+			if !isMountReady(path) {
+				return dep + " is not mounted"
+			}
+			continue
+		}
+		if path, ok := strings.CutPrefix(dep, "file:"); ok {
+			if _, err := os.Stat(path); err != nil {
+				return dep + " does not exist"
+			}
+			continue
+		}
+		d, ok := units[dep]
+		if !ok {
+			return dep + " is not a known service"
+		}
+		if !d.Enabled {
+			return dep + " is not enabled"
+		}
+		if atomic.LoadInt32(d.Started) == 0 {
+			return dep + " has not started"
+		}
+		if ready, managed := exeStart.DefaultSupervisor(logger).Ready(dep); managed && !ready {
+			return dep + " is not ready"
+		}
+	}
+	return ""
+}
+
+// isMountReady 判断挂载点是否已挂载。Linux 下读取 /proc/self/mounts，其他系统退化为目录存在且非空
+func isMountReady(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	abs = filepath.Clean(abs)
+	if data, err := os.ReadFile("/proc/self/mounts"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			mountPoint := strings.ReplaceAll(fields[1], `\040`, " ")
+			if filepath.Clean(mountPoint) == abs {
+				return true
+			}
+		}
+		return false
+	}
+	entries, err := os.ReadDir(abs)
+	return err == nil && len(entries) > 0
+}
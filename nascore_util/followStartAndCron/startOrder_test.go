@@ -0,0 +1,111 @@
+package followStartAndCron
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func unitNames(units []startUnit) []string {
+	names := make([]string, len(units))
+	for i, u := range units {
+		names[i] = u.Name
+	}
+	return names
+}
+
+func TestResolveStartOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		units     []startUnit
+		want      []string
+		wantCycle []string // 错误信息中应列出的启动项，为空表示没有环
+	}{
+		{"no dependencies keeps order", []startUnit{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			[]string{"a", "b", "c"}, nil},
+		{"after moves a unit behind its dependency", []startUnit{
+			{Name: "caddy2", After: []string{"openlist"}},
+			{Name: "ddnsgo"},
+			{Name: "openlist", Requires: []string{"rclone"}},
+			{Name: "rclone"},
+		}, []string{"ddnsgo", "rclone", "openlist", "caddy2"}, nil},
+		{"conditions, unknown names and self are ignored", []startUnit{
+			{Name: "a", Requires: []string{"mount:/mnt/a", "file:/etc/x", "missing", "a"}},
+			{Name: "b", After: []string{"a"}},
+		}, []string{"a", "b"}, nil},
+		{"same layer in original order", []startUnit{
+			{Name: "root"},
+			{Name: "x", After: []string{"root"}},
+			{Name: "y"},
+			{Name: "z", After: []string{"root"}},
+		}, []string{"root", "x", "y", "z"}, nil},
+		{"two unit cycle", []startUnit{
+			{Name: "a", After: []string{"b"}},
+			{Name: "b", Requires: []string{"a"}},
+			{Name: "c"},
+		}, []string{"c"}, []string{"a", "b"}},
+		{"three unit cycle", []startUnit{
+			{Name: "free"},
+			{Name: "a", After: []string{"c"}},
+			{Name: "b", After: []string{"a"}},
+			{Name: "c", After: []string{"b"}},
+		}, []string{"free"}, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		ordered, err := resolveStartOrder(tt.units)
+		if got := unitNames(ordered); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: order = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(tt.wantCycle) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "dependency cycle between: "+strings.Join(tt.wantCycle, ", ")) {
+			t.Errorf("%s: err = %v, want cycle %v", tt.name, err, tt.wantCycle)
+		}
+	}
+}
+
+func TestResolveStartOrderCycleBlocksDependents(t *testing.T) {
+	units := []startUnit{
+		{Name: "a", After: []string{"b"}},
+		{Name: "b", After: []string{"a"}},
+		{Name: "c", After: []string{"a"}}, // 不在环上，但依赖环上的启动项，同样无法排序
+	}
+	ordered, err := resolveStartOrder(units)
+	if err == nil || len(ordered) != 0 {
+		t.Fatalf("ordered = %v, err = %v", unitNames(ordered), err)
+	}
+}
+
+func TestUnmetRequirement(t *testing.T) {
+	dir := t.TempDir()
+	var started, stopped int32 = 1, 0
+	units := map[string]startUnit{
+		"up":       {Name: "up", Enabled: true, Started: &started},
+		"pending":  {Name: "pending", Enabled: true, Started: &stopped},
+		"disabled": {Name: "disabled", Started: &stopped},
+	}
+	tests := []struct {
+		requires []string
+		want     string
+	}{
+		{nil, ""},
+		{[]string{"up", "file:" + dir}, ""},
+		{[]string{"file:" + filepath.Join(dir, "missing")}, "file:" + filepath.Join(dir, "missing") + " does not exist"},
+		{[]string{"up", "pending"}, "pending has not started"},
+		{[]string{"disabled"}, "disabled is not enabled"},
+		{[]string{"nginx"}, "nginx is not a known service"},
+		{[]string{"mount:" + filepath.Join(dir, "not-mounted")}, "mount:" + filepath.Join(dir, "not-mounted") + " is not mounted"},
+	}
+	for _, tt := range tests {
+		if got := unmetRequirement(startUnit{Name: "svc", Requires: tt.requires}, units, zap.NewNop().Sugar()); got != tt.want {
+			t.Errorf("unmetRequirement(%v) = %q, want %q", tt.requires, got, tt.want)
+		}
+	}
+}
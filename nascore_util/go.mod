@@ -5,14 +5,15 @@ go 1.24.4
 toolchain go1.24.5
 
 require (
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/joyanhui/golang-pkgs/pkgs/exePath v0.0.0-20250712102146-8780ed189b72
+	github.com/mattn/go-sqlite3 v1.14.32
 	github.com/pelletier/go-toml/v2 v2.2.3
 	github.com/spf13/viper v1.20.1
 	go.uber.org/zap v1.27.0
 )
 
 require (
-	github.com/fsnotify/fsnotify v1.8.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
 	github.com/sagikazarmark/locafero v0.7.0 // indirect
 	github.com/sourcegraph/conc v0.3.0 // indirect
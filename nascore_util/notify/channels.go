@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+const (
+	ChannelSMTP    = "smtp"
+	ChannelWebhook = "webhook"
+	ChannelBot     = "bot"
+
+	sendTimeout      = 15 * time.Second
+	defaultBotApiUrl = "https://api.telegram.org"
+)
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// Send 通过单个渠道发送消息
+func Send(ch system_config.NotifyChannelStru, secret []byte, msg Message) error {
+	switch strings.ToLower(ch.Type) {
+	case ChannelSMTP:
+		return sendSMTP(ch, msg)
+	case ChannelWebhook:
+		return sendWebhook(ch, secret, msg)
+	case ChannelBot:
+		return sendBot(ch, msg)
+	default:
+		return fmt.Errorf("unknown notify channel type %q", ch.Type)
+	}
+}
+
+// sendSMTP 发送纯文本邮件。SmtpSSL 时直接建立 TLS 连接，否则服务器支持时升级为 STARTTLS；
+// 未配置用户名时不认证，便于使用本机的邮件中继
+func sendSMTP(ch system_config.NotifyChannelStru, msg Message) error {
+	if ch.SmtpHost == "" || ch.SmtpFrom == "" || len(ch.SmtpTo) == 0 {
+		return fmt.Errorf("smtp channel %s requires SmtpHost, SmtpFrom and SmtpTo", ch.Name)
+	}
+	port := ch.SmtpPort
+	if port == 0 {
+		port = 25
+		if ch.SmtpSSL {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(ch.SmtpHost, strconv.Itoa(port))
+	tlsCfg := &tls.Config{ServerName: ch.SmtpHost}
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	var err error
+	if ch.SmtpSSL {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+	c, err := smtp.NewClient(conn, ch.SmtpHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if !ch.SmtpSSL {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsCfg); err != nil {
+				return err
+			}
+		}
+	}
+	if ch.SmtpUser != "" {
+		if err := c.Auth(smtp.PlainAuth("", ch.SmtpUser, ch.SmtpPassword, ch.SmtpHost)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(ch.SmtpFrom); err != nil {
+		return err
+	}
+	for _, to := range ch.SmtpTo {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMail(ch.SmtpFrom, ch.SmtpTo, msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func buildMail(from string, to []string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n")))
+	qp.Close()
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// sendWebhook 以 JSON POST 消息，签名方式与事件 webhook 相同
+func sendWebhook(ch system_config.NotifyChannelStru, secret []byte, msg Message) error {
+	if ch.Url == "" {
+		return fmt.Errorf("webhook channel %s requires Url", ch.Name)
+	}
+	body, err := json.Marshal(map[string]any{"title": msg.Title, "text": msg.Text, "event": msg.Event})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, ch.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(eventbus.HeaderTimestamp, ts)
+		req.Header.Set(eventbus.HeaderSignature, eventbus.Sign(secret, ts, body))
+	}
+	return doRequest(req)
+}
+
+// sendBot 调用 Telegram 风格的 bot API：POST {BotApiUrl}/bot{BotToken}/sendMessage
+func sendBot(ch system_config.NotifyChannelStru, msg Message) error {
+	if ch.BotToken == "" || ch.BotChatId == "" {
+		return fmt.Errorf("bot channel %s requires BotToken and BotChatId", ch.Name)
+	}
+	apiUrl := ch.BotApiUrl
+	if apiUrl == "" {
+		apiUrl = defaultBotApiUrl
+	}
+	body, err := json.Marshal(map[string]any{
+		"chat_id":                  ch.BotChatId,
+		"text":                     msg.Title + "\n\n" + msg.Text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(apiUrl, "/")+"/bot"+ch.BotToken+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(req)
+}
+
+func doRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 错误信息中的 URL 可能带有 bot token，只保留底层错误
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("%s request failed: %w", req.URL.Host, uerr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return nil
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+// fakeSMTP 只实现发送一封邮件所需命令的 SMTP 服务器，记录收到的命令与邮件内容
+type fakeSMTP struct {
+	ln       net.Listener
+	commands chan []string
+}
+
+func newFakeSMTP(t *testing.T, rejectRcpt string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, commands: make(chan []string, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var got []string
+		defer func() { s.commands <- got }()
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+			verb := strings.ToUpper(strings.Fields(line + " x")[0])
+			switch {
+			case verb == "EHLO":
+				reply("250-fake")
+				reply("250 AUTH PLAIN")
+			case verb == "AUTH":
+				reply("235 ok")
+			case verb == "RCPT" && rejectRcpt != "" && strings.Contains(line, rejectRcpt):
+				reply("550 no such user")
+			case verb == "DATA":
+				reply("354 go ahead")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				got = append(got, body.String())
+				reply("250 queued")
+			case verb == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) channel(t *testing.T) system_config.NotifyChannelStru {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return system_config.NotifyChannelStru{
+		Name: "mail", Type: ChannelSMTP, Enable: true,
+		SmtpHost: host, SmtpPort: p,
+		SmtpFrom: "nascore@example.com", SmtpTo: []string{"a@example.com", "b@example.com"},
+	}
+}
+
+func TestSendSMTP(t *testing.T) {
+	srv := newFakeSMTP(t, "")
+	ch := srv.channel(t)
+	ch.SmtpUser, ch.SmtpPassword = "user", "pass"
+	msg := Message{Title: "[nascore] job.failed: 备份", Text: "Scheduled job backup failed.\nexit status 1"}
+	if err := Send(ch, nil, msg); err != nil {
+		t.Fatalf("sendSMTP: %v", err)
+	}
+	got := <-srv.commands
+	joined := strings.Join(got, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<nascore@example.com>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+		"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00user\x00pass")),
+		"Subject: =?utf-8?q?",
+		"Content-Transfer-Encoding: quoted-printable",
+		"Scheduled job backup failed.\r\nexit status 1",
+		"QUIT",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("smtp session missing %q:\n%s", want, joined)
+		}
+	}
+}
+
+func TestSendSMTPErrors(t *testing.T) {
+	srv := newFakeSMTP(t, "b@example.com")
+	if err := sendSMTP(srv.channel(t), Message{Title: "t", Text: "x"}); err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("rejected recipient err = %v", err)
+	}
+	if err := sendSMTP(system_config.NotifyChannelStru{Name: "mail"}, Message{}); err == nil {
+		t.Fatal("missing SmtpHost should fail")
+	}
+}
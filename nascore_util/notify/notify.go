@@ -0,0 +1,284 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+var ErrChannelNotFound = errors.New("notify channel not found")
+
+// DefaultEvents 未配置路由时通知的事件
+var DefaultEvents = []string{
+	eventbus.TypeJobFailed,
+	eventbus.TypeJobTimeout,
+	eventbus.TypeServiceGaveUp,
+	eventbus.TypeServiceUnhealthy,
+	eventbus.TypeCertExpiring,
+}
+
+// Message 发送到各渠道的消息
+type Message struct {
+	Title string
+	Text  string
+	Event *eventbus.Event // 由事件触发时不为空
+}
+
+// Notifier 按路由把事件转换成消息发送到各渠道，同一对象的同类事件在去重窗口内只发一次，每个渠道按小时限流
+type Notifier struct {
+	cfg    system_config.NotifyStru
+	secret []byte
+	logger *zap.SugaredLogger
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time   // 去重 key -> 最近一次通知时间
+	sent       map[string][]time.Time // 渠道名 -> 最近一小时内的发送时间
+	suppressed map[string]int         // 渠道名 -> 因限流丢弃的消息数
+}
+
+// New 创建通知器，secret 用于 webhook 渠道的签名
+func New(cfg system_config.NotifyStru, secret []byte, logger *zap.SugaredLogger) *Notifier {
+	return &Notifier{
+		cfg:        cfg,
+		secret:     secret,
+		logger:     logger,
+		lastSent:   make(map[string]time.Time),
+		sent:       make(map[string][]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// HandleEvent 按路由通知事件，可直接作为 eventbus.Handler 使用
+func (n *Notifier) HandleEvent(e eventbus.Event) {
+	channels := n.route(e.Type)
+	if len(channels) == 0 {
+		return
+	}
+	key := e.Type + "|" + eventSubject(e)
+	now := time.Now()
+	if !n.dedup(key, now) {
+		n.logger.Debugf("[notify] %s suppressed as duplicate", key)
+		return
+	}
+	msg := FormatEvent(e)
+	for _, ch := range channels {
+		extra := n.allow(ch.Name, now)
+		if extra < 0 {
+			n.logger.Warnf("[notify] channel %s rate limited, dropped %s", ch.Name, msg.Title)
+			continue
+		}
+		m := msg
+		if extra > 0 {
+			m.Text += fmt.Sprintf("\n\n(%d earlier notifications were dropped by rate limit)", extra)
+		}
+		n.deliver(ch, m)
+	}
+}
+
+// Test 向指定渠道发送测试消息，不受去重与限流影响
+func (n *Notifier) Test(channel string) error {
+	for _, ch := range n.cfg.Channels {
+		if ch.Name == channel {
+			return Send(ch, n.secret, Message{
+				Title: "[nascore] test notification",
+				Text:  "This is a test notification from nascore, sent at " + time.Now().Format(time.RFC3339) + ".",
+			})
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrChannelNotFound, channel)
+}
+
+func (n *Notifier) deliver(ch system_config.NotifyChannelStru, msg Message) {
+	if err := Send(ch, n.secret, msg); err != nil {
+		n.logger.Errorf("[notify] send %q via %s err: %v", msg.Title, ch.Name, err)
+		return
+	}
+	n.logger.Debugf("[notify] sent %q via %s", msg.Title, ch.Name)
+}
+
+// route 返回事件需要发送到的已启用渠道，多条路由命中同一渠道时只发送一次
+func (n *Notifier) route(typ string) []system_config.NotifyChannelStru {
+	routes := n.cfg.Routes
+	if len(routes) == 0 {
+		routes = []system_config.NotifyRouteStru{{Events: DefaultEvents}}
+	}
+	want := make(map[string]bool)
+	all := false
+	for _, r := range routes {
+		if !matchAny(r.Events, typ) {
+			continue
+		}
+		if len(r.Channels) == 0 {
+			all = true
+		}
+		for _, name := range r.Channels {
+			want[name] = true
+		}
+	}
+	var channels []system_config.NotifyChannelStru
+	for _, ch := range n.cfg.Channels {
+		if ch.Enable && (all || want[ch.Name]) {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+func matchAny(patterns []string, typ string) bool {
+	for _, p := range patterns {
+		if eventbus.Match(p, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// dedup 去重窗口内已通知过时返回 false
+func (n *Notifier) dedup(key string, now time.Time) bool {
+	window := time.Duration(n.cfg.DedupWindowMin) * time.Minute
+	if window <= 0 {
+		return true
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, t := range n.lastSent {
+		if now.Sub(t) >= window {
+			delete(n.lastSent, k)
+		}
+	}
+	if _, ok := n.lastSent[key]; ok {
+		return false
+	}
+	n.lastSent[key] = now
+	return true
+}
+
+// allow 检查渠道的限流，被限流时返回 -1，否则返回此前被丢弃的消息数并清零
+func (n *Notifier) allow(channel string, now time.Time) int {
+	limit := n.cfg.RateLimitPerHour
+	if limit <= 0 {
+		return 0
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	recent := n.sent[channel][:0]
+	for _, t := range n.sent[channel] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		n.sent[channel] = recent
+		n.suppressed[channel]++
+		return -1
+	}
+	n.sent[channel] = append(recent, now)
+	extra := n.suppressed[channel]
+	n.suppressed[channel] = 0
+	return extra
+}
+
+// eventSubject 事件所属对象，用于去重和标题
+func eventSubject(e eventbus.Event) string {
+	for _, k := range []string{"job", "service", "domain"} {
+		if v, ok := e.Data[k]; ok {
+			return fmt.Sprint(v)
+		}
+	}
+	return e.Source
+}
+
+// FormatEvent 把事件格式化为通知消息
+func FormatEvent(e eventbus.Event) Message {
+	subject := eventSubject(e)
+	var summary string
+	switch e.Type {
+	case eventbus.TypeJobFailed:
+		summary = fmt.Sprintf("Scheduled job %s failed.", subject)
+	case eventbus.TypeJobTimeout:
+		summary = fmt.Sprintf("Scheduled job %s timed out and was cancelled.", subject)
+	case eventbus.TypeServiceGaveUp:
+		summary = fmt.Sprintf("Service %s keeps crashing, automatic restarts have stopped.", subject)
+	case eventbus.TypeServiceUnhealthy:
+		summary = fmt.Sprintf("Service %s failed its liveness probe and was killed.", subject)
+	case eventbus.TypeCertExpiring:
+		summary = fmt.Sprintf("Certificate for %s expires in %v days.", subject, e.Data["days_left"])
+	default:
+		summary = fmt.Sprintf("Event %s from %s.", e.Type, e.Source)
+	}
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(summary)
+	b.WriteString("\n\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %v\n", k, e.Data[k])
+	}
+	fmt.Fprintf(&b, "time: %s\n", e.Time.Format(time.RFC3339))
+	return Message{
+		Title: fmt.Sprintf("[nascore] %s: %s", e.Type, subject),
+		Text:  strings.TrimRight(b.String(), "\n"),
+		Event: &e,
+	}
+}
+
+var (
+	applyMu sync.Mutex
+	applied *appliedNotifier
+	current *Notifier
+)
+
+type appliedNotifier struct {
+	cfg    system_config.NotifyStru
+	secret string
+	unsub  func()
+}
+
+// ApplyConfig 按配置订阅全局事件总线，配置未变化时不做任何事，可在每次热重载后调用
+func ApplyConfig(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger) {
+	next := &appliedNotifier{cfg: nsCfg.Notify, secret: nsCfg.Events.WebhookSecret}
+	applyMu.Lock()
+	defer applyMu.Unlock()
+	if prev := applied; prev != nil {
+		if reflect.DeepEqual(prev.cfg, next.cfg) && prev.secret == next.secret {
+			return
+		}
+		if prev.unsub != nil {
+			go prev.unsub() // 等待已排队的通知发送完成，不能阻塞调用方
+		}
+	}
+	n := New(next.cfg, []byte(next.secret), logger)
+	enabled := 0
+	for _, ch := range next.cfg.Channels {
+		if ch.Enable {
+			enabled++
+		}
+	}
+	if enabled > 0 {
+		next.unsub = eventbus.Default().Subscribe("*", n.HandleEvent)
+		logger.Debugf("[notify] %d channels enabled", enabled)
+	}
+	applied = next
+	current = n
+}
+
+// Test 使用当前配置向指定渠道发送测试消息
+func Test(nsCfg *system_config.SysCfg, logger *zap.SugaredLogger, channel string) error {
+	ApplyConfig(nsCfg, logger)
+	applyMu.Lock()
+	n := current
+	applyMu.Unlock()
+	return n.Test(channel)
+}
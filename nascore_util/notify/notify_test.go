@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/eventbus"
+	"github.com/nas-core/nascore/nascore_util/system_config"
+
+	"go.uber.org/zap"
+)
+
+func TestRoute(t *testing.T) {
+	channels := []system_config.NotifyChannelStru{
+		{Name: "mail", Enable: true},
+		{Name: "bot", Enable: true},
+		{Name: "off", Enable: false},
+	}
+	tests := []struct {
+		name   string
+		routes []system_config.NotifyRouteStru
+		typ    string
+		want   []string
+	}{
+		{"default events", nil, eventbus.TypeJobFailed, []string{"mail", "bot"}},
+		{"not a default event", nil, eventbus.TypeJobSucceeded, nil},
+		{"wildcard to one channel", []system_config.NotifyRouteStru{{Events: []string{"service.*"}, Channels: []string{"bot"}}}, eventbus.TypeServiceExited, []string{"bot"}},
+		{"no match", []system_config.NotifyRouteStru{{Events: []string{"service.*"}, Channels: []string{"bot"}}}, eventbus.TypeJobFailed, nil},
+		{"empty channels means all enabled", []system_config.NotifyRouteStru{{Events: []string{"job.*"}}}, eventbus.TypeJobTimeout, []string{"mail", "bot"}},
+		{"overlapping routes send once", []system_config.NotifyRouteStru{
+			{Events: []string{"job.*"}, Channels: []string{"mail"}},
+			{Events: []string{eventbus.TypeJobFailed}, Channels: []string{"mail", "off"}},
+		}, eventbus.TypeJobFailed, []string{"mail"}},
+	}
+	for _, tt := range tests {
+		n := New(system_config.NotifyStru{Channels: channels, Routes: tt.routes}, nil, zap.NewNop().Sugar())
+		var got []string
+		for _, ch := range n.route(tt.typ) {
+			got = append(got, ch.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: route(%s) = %v, want %v", tt.name, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	n := New(system_config.NotifyStru{DedupWindowMin: 10}, nil, zap.NewNop().Sugar())
+	now := time.Now()
+	steps := []struct {
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{"job.failed|backup", 0, true},
+		{"job.failed|backup", time.Minute, false},
+		{"job.failed|other", time.Minute, true},
+		{"job.timeout|backup", time.Minute, true},
+		{"job.failed|backup", 10 * time.Minute, true}, // 窗口过后再次通知
+		{"job.failed|backup", 11 * time.Minute, false},
+	}
+	for _, s := range steps {
+		if got := n.dedup(s.key, now.Add(s.after)); got != s.want {
+			t.Errorf("dedup(%s, +%v) = %v, want %v", s.key, s.after, got, s.want)
+		}
+	}
+
+	off := New(system_config.NotifyStru{}, nil, zap.NewNop().Sugar())
+	if !off.dedup("k", now) || !off.dedup("k", now) {
+		t.Error("dedup with DedupWindowMin 0 should always allow")
+	}
+}
+
+func TestAllow(t *testing.T) {
+	n := New(system_config.NotifyStru{RateLimitPerHour: 2}, nil, zap.NewNop().Sugar())
+	now := time.Now()
+	steps := []struct {
+		channel string
+		after   time.Duration
+		want    int
+	}{
+		{"mail", 0, 0},
+		{"mail", time.Minute, 0},
+		{"mail", 2 * time.Minute, -1},
+		{"mail", 3 * time.Minute, -1},
+		{"bot", 3 * time.Minute, 0},    // 每个渠道单独计数
+		{"mail", 61 * time.Minute, 2},  // 第一条已超过一小时，放行并带上被丢弃的条数
+		{"mail", 62 * time.Minute, 0},  // 第二条已过期，计数清零后放行
+		{"mail", 63 * time.Minute, -1}, // 61 与 62 分钟的两条仍在一小时内
+	}
+	for _, s := range steps {
+		if got := n.allow(s.channel, now.Add(s.after)); got != s.want {
+			t.Errorf("allow(%s, +%v) = %d, want %d", s.channel, s.after, got, s.want)
+		}
+	}
+
+	off := New(system_config.NotifyStru{}, nil, zap.NewNop().Sugar())
+	for i := 0; i < 100; i++ {
+		if off.allow("mail", now) != 0 {
+			t.Fatal("allow with RateLimitPerHour 0 should never limit")
+		}
+	}
+}
+
+func TestHandleEventReportsSuppressed(t *testing.T) {
+	srv := newFakeSMTP(t, "")
+	ch := srv.channel(t)
+	n := New(system_config.NotifyStru{Channels: []system_config.NotifyChannelStru{ch}, RateLimitPerHour: 1}, nil, zap.NewNop().Sugar())
+	past := time.Now().Add(-2 * time.Hour)
+	n.sent[ch.Name] = []time.Time{past}
+	n.suppressed[ch.Name] = 3
+
+	n.HandleEvent(eventbus.Event{Type: eventbus.TypeJobFailed, Source: "scheduler", Time: time.Now(), Data: map[string]any{"job": "backup"}})
+	got := <-srv.commands
+	body := got[len(got)-2] // DATA 之后的邮件内容，最后一条是 QUIT
+	if !strings.Contains(body, "3 earlier notifications were dropped by rate limit") {
+		t.Fatalf("mail does not report the suppressed count:\n%s", body)
+	}
+	if n.suppressed[ch.Name] != 0 {
+		t.Fatalf("suppressed count not reset: %d", n.suppressed[ch.Name])
+	}
+}
@@ -0,0 +1,205 @@
+// Package secretmask 在日志与 API 输出中隐藏密钥。名称匹配通配符（例如 *TOKEN*）的环境变量的值登记为密钥，
+// 之后任何输出中出现这些值都替换为 ***
+package secretmask
+
+import (
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask 替换密钥的文本
+const Mask = "***"
+
+const (
+	minSecretLen = 4    // 过短的值（例如 true、1）替换后反而让日志无法阅读，不登记
+	maxSecrets   = 1024 // 防止反复改写的配置让列表无限增长，超过时忘记最久没有再登记的值
+)
+
+// DefaultPatterns 未配置 SecretEnvPatterns 时使用的名称通配符
+var DefaultPatterns = []string{"*TOKEN*", "*SECRET*", "*KEY*", "*HMAC*", "*PASSWORD*"}
+
+// Masker 记录已知的密钥值并在文本中替换
+type Masker struct {
+	mu       sync.RWMutex
+	patterns []string
+	values   []string          // 按长度从长到短，避免较短的值先替换掉较长值的一部分
+	known    map[string]uint64 // 值 -> 最近一次登记的序号，仍在使用的环境变量与命令块每次执行都会重新登记
+	seq      uint64
+	evicted  bool // 已提示过超出上限，只提示一次
+	replacer *strings.Replacer
+}
+
+// New 创建 Masker，patterns 为空时使用 DefaultPatterns
+func New(patterns []string) *Masker {
+	m := &Masker{known: make(map[string]uint64)}
+	m.SetPatterns(patterns)
+	return m
+}
+
+var defaultMasker = New(nil)
+
+// Default 返回进程内共享的 Masker
+func Default() *Masker {
+	return defaultMasker
+}
+
+// SetPatterns 替换名称通配符，大小写不敏感，已登记的值保留
+func (m *Masker) SetPatterns(patterns []string) {
+	if len(patterns) == 0 {
+		patterns = DefaultPatterns
+	}
+	upper := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			upper = append(upper, strings.ToUpper(p))
+		}
+	}
+	m.mu.Lock()
+	m.patterns = upper
+	m.mu.Unlock()
+}
+
+// IsSecretName 环境变量名是否匹配任一通配符
+func (m *Masker) IsSecretName(name string) bool {
+	name = strings.ToUpper(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Add 登记密钥值。超过 maxSecrets 时忘记最久没有再登记的值，它们通常来自已修改的配置
+func (m *Masker) Add(values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := false
+	for _, v := range values {
+		if len(v) < minSecretLen {
+			continue
+		}
+		m.seq++
+		if _, ok := m.known[v]; !ok {
+			changed = true
+		}
+		m.known[v] = m.seq
+	}
+	if !changed {
+		return
+	}
+	m.values = m.values[:0]
+	for v := range m.known {
+		m.values = append(m.values, v)
+	}
+	if evict := len(m.values) - maxSecrets; evict > 0 {
+		sort.Slice(m.values, func(i, j int) bool { return m.known[m.values[i]] < m.known[m.values[j]] })
+		for _, v := range m.values[:evict] {
+			delete(m.known, v)
+		}
+		m.values = append(m.values[:0], m.values[evict:]...)
+		if !m.evicted {
+			m.evicted = true
+			log.Printf("[secretmask] more than %d secrets registered, forgetting the least recently registered values", maxSecrets)
+		}
+	}
+	sort.SliceStable(m.values, func(i, j int) bool { return len(m.values[i]) > len(m.values[j]) })
+	pairs := make([]string, 0, len(m.values)*2)
+	for _, v := range m.values {
+		pairs = append(pairs, v, Mask)
+	}
+	m.replacer = strings.NewReplacer(pairs...)
+}
+
+// AddEnv 登记 KEY=VALUE 列表中名称匹配通配符的值
+func (m *Masker) AddEnv(env []string) {
+	var values []string
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok && m.IsSecretName(name) {
+			values = append(values, value)
+		}
+	}
+	if len(values) > 0 {
+		m.Add(values...)
+	}
+}
+
+// String 替换文本中已登记的密钥值
+func (m *Masker) String(s string) string {
+	m.mu.RLock()
+	r := m.replacer
+	m.mu.RUnlock()
+	if r == nil || s == "" {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// Strings 返回替换后的副本
+func (m *Masker) Strings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = m.String(s)
+	}
+	return out
+}
+
+// Env 返回 KEY=VALUE 列表的副本，名称匹配通配符的值显示为 ***，其余值中的已知密钥同样替换
+func (m *Masker) Env(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	out := make([]string, len(env))
+	for i, kv := range env {
+		name, _, ok := strings.Cut(kv, "=")
+		if ok && m.IsSecretName(name) {
+			out[i] = name + "=" + Mask
+		} else {
+			out[i] = m.String(kv)
+		}
+	}
+	return out
+}
+
+// Error 返回错误信息中的密钥被替换后的错误，Unwrap 仍返回原错误，不影响 errors.Is 判断
+func (m *Masker) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if masked := m.String(msg); masked != msg {
+		return &maskedError{msg: masked, err: err}
+	}
+	return err
+}
+
+type maskedError struct {
+	msg string
+	err error
+}
+
+func (e *maskedError) Error() string { return e.msg }
+func (e *maskedError) Unwrap() error { return e.err }
+
+// String 使用共享的 Masker 替换文本
+func String(s string) string {
+	return defaultMasker.String(s)
+}
+
+// Strings 使用共享的 Masker 替换列表
+func Strings(list []string) []string {
+	return defaultMasker.Strings(list)
+}
+
+// Error 使用共享的 Masker 替换错误信息
+func Error(err error) error {
+	return defaultMasker.Error(err)
+}
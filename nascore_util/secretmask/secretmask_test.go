@@ -0,0 +1,110 @@
+package secretmask
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+)
+
+func TestIsSecretName(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		want     bool
+	}{
+		{nil, "GITHUB_TOKEN", true},
+		{nil, "aws_secret_access_key", true}, // 大小写不敏感
+		{nil, "DB_PASSWORD", true},
+		{nil, "PATH", false},
+		{[]string{"CF_*"}, "CF_API", true},
+		{[]string{"CF_*"}, "GITHUB_TOKEN", false}, // 配置后不再使用默认通配符
+		{[]string{" ", "*AUTH"}, "X_AUTH", true},
+	}
+	for _, tt := range tests {
+		if got := New(tt.patterns).IsSecretName(tt.name); got != tt.want {
+			t.Errorf("patterns %v IsSecretName(%s) = %v, want %v", tt.patterns, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	m := New(nil)
+	m.Add("abc", "secret-value", "secret-value-long", "tok1")
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"nothing to hide", "nothing to hide"},
+		{"abc is too short to register", "abc is too short to register"},
+		{"token=tok1", "token=***"},
+		{"a secret-value-long b", "a *** b"}, // 较长的值先替换，不会留下 -long
+		{"secret-value and tok1tok1", "*** and ******"},
+	}
+	for _, tt := range tests {
+		if got := m.String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := New(nil).String("tok1"); got != "tok1" {
+		t.Errorf("empty masker String = %q", got)
+	}
+}
+
+func TestAddEnvAndEnv(t *testing.T) {
+	m := New(nil)
+	m.AddEnv([]string{"API_TOKEN=t0ken-value", "HOME=/root", "PASSWORD", "KEY=ab"})
+	env := []string{"API_TOKEN=t0ken-value", "URL=https://x/?t=t0ken-value", "KEY=ab", "HOME=/root"}
+	want := []string{"API_TOKEN=***", "URL=https://x/?t=***", "KEY=***", "HOME=/root"}
+	if got := m.Env(env); !slices.Equal(got, want) {
+		t.Fatalf("Env = %v, want %v", got, want)
+	}
+	if got := m.String("ab"); got != "ab" { // 过短的值只在名称匹配时隐藏
+		t.Fatalf("short value registered: %q", got)
+	}
+	if m.Env(nil) != nil || m.Strings(nil) != nil {
+		t.Fatal("nil input should stay nil")
+	}
+}
+
+func TestSetPatternsKeepsValues(t *testing.T) {
+	m := New(nil)
+	m.AddEnv([]string{"MY_TOKEN=value-1"})
+	m.SetPatterns([]string{"CF_*"})
+	m.AddEnv([]string{"CF_API=value-2", "MY_TOKEN=value-3"})
+	if got := m.String("value-1 value-2 value-3"); got != "*** *** value-3" {
+		t.Fatalf("String = %q", got)
+	}
+}
+
+func TestError(t *testing.T) {
+	m := New(nil)
+	m.Add("hunter22")
+	base := fmt.Errorf("login with hunter22: %w", fs.ErrPermission)
+	err := m.Error(base)
+	if err.Error() != "login with ***: permission denied" || !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Error = %v", err)
+	}
+	plain := errors.New("plain")
+	if m.Error(plain) != plain || m.Error(nil) != nil {
+		t.Fatal("errors without secrets should be returned as is")
+	}
+}
+
+func TestEvictLeastRecentlyAdded(t *testing.T) {
+	m := New(nil)
+	m.Add("first-secret")
+	for i := 0; i < maxSecrets; i++ {
+		m.Add(fmt.Sprintf("secret-%04d", i))
+	}
+	if got := m.String("first-secret"); got != "first-secret" {
+		t.Fatalf("oldest value not evicted: %q", got)
+	}
+	if got := m.String(fmt.Sprintf("secret-%04d", maxSecrets-1)); got != Mask {
+		t.Fatalf("newest value evicted: %q", got)
+	}
+	if len(m.known) != maxSecrets {
+		t.Fatalf("known = %d, want %d", len(m.known), maxSecrets)
+	}
+}
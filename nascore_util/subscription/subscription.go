@@ -2,6 +2,7 @@ package subscription
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/nas-core/nascore/nascore_util/eventbus"
 	"github.com/pelletier/go-toml/v2"
 
 	"github.com/spf13/viper"
@@ -49,6 +51,11 @@ func init() {
 
 // FetchAndMergeSubscriptions 从给定的 URL 列表获取 TOML 配置并合并。
 func FetchAndMergeSubscriptions(githubDownloadMirror string, logger *zap.SugaredLogger, urls []string) (ApiSitesConfig, error) {
+	return FetchAndMergeSubscriptionsContext(context.Background(), githubDownloadMirror, logger, urls)
+}
+
+// FetchAndMergeSubscriptionsContext 与 FetchAndMergeSubscriptions 相同，ctx 取消或超时后中止请求
+func FetchAndMergeSubscriptionsContext(ctx context.Context, githubDownloadMirror string, logger *zap.SugaredLogger, urls []string) (ApiSitesConfig, error) {
 	mergedConfig := make(ApiSitesConfig)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
@@ -69,7 +76,12 @@ func FetchAndMergeSubscriptions(githubDownloadMirror string, logger *zap.Sugared
 				}
 			}
 			logger.Debug("[subscription] Start fetching subscription source: %s", u)
-			resp, err := http.Get(u)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+			if err != nil {
+				logger.Errorf("[subscription] Invalid subscription source %s: %v", u, err)
+				return
+			}
+			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				logger.Errorf("[subscription] Failed to fetch subscription source %s: %v", u, err)
 				return
@@ -112,6 +124,9 @@ func FetchAndMergeSubscriptions(githubDownloadMirror string, logger *zap.Sugared
 		}(url)
 	}
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err // 超时后结果不完整，不能覆盖已保存的订阅
+	}
 
 	// 按键名排序
 	sortedKeys := make([]string, 0, len(mergedConfig))
@@ -232,7 +247,12 @@ func LoadSubscriptionFromDB(db *sql.DB, logger *zap.SugaredLogger) (ApiSitesConf
 
 // MergeRemoteSubscriptions 拉取并合并远程订阅，返回结构和TOML字符串
 func MergeRemoteSubscriptions(urls []string, mirror string, logger *zap.SugaredLogger) (ApiSitesConfig, string, error) {
-	merged, err := FetchAndMergeSubscriptions(mirror, logger, urls)
+	return MergeRemoteSubscriptionsContext(context.Background(), urls, mirror, logger)
+}
+
+// MergeRemoteSubscriptionsContext 与 MergeRemoteSubscriptions 相同，ctx 取消或超时后中止请求
+func MergeRemoteSubscriptionsContext(ctx context.Context, urls []string, mirror string, logger *zap.SugaredLogger) (ApiSitesConfig, string, error) {
+	merged, err := FetchAndMergeSubscriptionsContext(ctx, mirror, logger, urls)
 	if err != nil {
 		return nil, "", err
 	}
@@ -245,9 +265,19 @@ func MergeRemoteSubscriptions(urls []string, mirror string, logger *zap.SugaredL
 
 // RefreshSubscriptionAndSaveToDB 拉取合并并写入DB
 func RefreshSubscriptionAndSaveToDB(db *sql.DB, urls []string, mirror string, logger *zap.SugaredLogger) error {
-	_, tomlStr, err := MergeRemoteSubscriptions(urls, mirror, logger)
+	return RefreshSubscriptionAndSaveToDBContext(context.Background(), db, urls, mirror, logger)
+}
+
+// RefreshSubscriptionAndSaveToDBContext 与 RefreshSubscriptionAndSaveToDB 相同，ctx 取消或超时后中止请求
+func RefreshSubscriptionAndSaveToDBContext(ctx context.Context, db *sql.DB, urls []string, mirror string, logger *zap.SugaredLogger) error {
+	merged, tomlStr, err := MergeRemoteSubscriptionsContext(ctx, urls, mirror, logger)
+	if err == nil {
+		err = SaveSubscriptionToDB(db, tomlStr)
+	}
 	if err != nil {
+		eventbus.Publish(eventbus.TypeSubscriptionRefreshFailed, "subscription", map[string]any{"urls": len(urls), "error": err.Error()})
 		return err
 	}
-	return SaveSubscriptionToDB(db, tomlStr)
+	eventbus.Publish(eventbus.TypeSubscriptionRefreshed, "subscription", map[string]any{"urls": len(urls), "sites": len(merged)})
+	return nil
 }
@@ -15,6 +15,60 @@ type SysCfg struct {
 
 	NascoreExt    NascoreExtStru    `mapstructure:"NascoreExt"`
 	ThirdPartyExt ThirdPartyExtStru `mapstructure:"ThirdPartyExt"`
+
+	Events EventsStru `mapstructure:"Events"`
+	Notify NotifyStru `mapstructure:"Notify"`
+}
+
+// EventsStru 事件通知配置，服务启停、计划任务结果、配置重载等事件会推送到 Webhooks
+type EventsStru struct {
+	Webhooks       []WebhookStru `mapstructure:"Webhooks"`
+	DeadLetterFile string        `mapstructure:"DeadLetterFile"` // 重试后仍投递失败的事件写入此文件，为空时使用 TempFilePath 下的 logs/webhook_dead_letter.log
+	WebhookSecret  string        `mapstructure:"WebhookSecret"`  // Webhooks 与通知 webhook 渠道的签名密钥，为空时自动生成并保存到 Secret.SecretsFile
+}
+
+// NotifyStru 故障通知配置，任务失败、服务反复崩溃、证书即将过期时通过邮件、webhook 或聊天机器人通知
+type NotifyStru struct {
+	Channels           []NotifyChannelStru `mapstructure:"Channels"`
+	Routes             []NotifyRouteStru   `mapstructure:"Routes"`             // 为空时把默认关注的事件发送到所有渠道
+	RateLimitPerHour   int                 `mapstructure:"RateLimitPerHour"`   // 每个渠道每小时最多发送的消息数，0 表示不限制
+	DedupWindowMin     int                 `mapstructure:"DedupWindowMin"`     // 同一对象的同类事件在此时间内只通知一次，0 表示不去重
+	CertExpiryWarnDays int                 `mapstructure:"CertExpiryWarnDays"` // lego 证书剩余有效期少于此天数时提醒，0 表示不检查
+	CertCheckCron      string              `mapstructure:"CertCheckCron"`      // 证书有效期检查的 cron 表达式
+}
+
+// NotifyChannelStru 通知渠道，Type 为 smtp、webhook 或 bot，各类型只使用对应前缀的字段
+type NotifyChannelStru struct {
+	Name         string   `mapstructure:"Name"`
+	Type         string   `mapstructure:"Type"`
+	Enable       bool     `mapstructure:"Enable"`
+	SmtpHost     string   `mapstructure:"SmtpHost"`
+	SmtpPort     int      `mapstructure:"SmtpPort"`
+	SmtpSSL      bool     `mapstructure:"SmtpSSL"` // 直接使用 TLS 连接（通常是 465 端口），否则在服务器支持时使用 STARTTLS
+	SmtpUser     string   `mapstructure:"SmtpUser"`
+	SmtpPassword string   `mapstructure:"SmtpPassword"`
+	SmtpFrom     string   `mapstructure:"SmtpFrom"`
+	SmtpTo       []string `mapstructure:"SmtpTo"`
+	Url          string   `mapstructure:"Url"`       // webhook 地址，请求体为 {"title","text","event"}
+	BotApiUrl    string   `mapstructure:"BotApiUrl"` // Telegram 风格的 bot API 地址，为空时使用 https://api.telegram.org
+	BotToken     string   `mapstructure:"BotToken"`
+	BotChatId    string   `mapstructure:"BotChatId"`
+}
+
+// NotifyRouteStru 路由规则，匹配 Events 的事件发送到 Channels
+type NotifyRouteStru struct {
+	Events   []string `mapstructure:"Events"`   // 支持 job.* 这样的通配符
+	Channels []string `mapstructure:"Channels"` // 渠道名，为空表示全部渠道
+}
+
+// WebhookStru 单个 webhook，请求体为 JSON，使用 Events.WebhookSecret 做 HMAC-SHA256 签名
+type WebhookStru struct {
+	Name       string   `mapstructure:"Name"`
+	Enable     bool     `mapstructure:"Enable"`
+	Url        string   `mapstructure:"Url"`
+	Events     []string `mapstructure:"Events"`     // 订阅的事件类型，支持 service.* 这样的通配符，为空表示全部
+	MaxRetries int      `mapstructure:"MaxRetries"` // 失败重试次数，0 使用默认值 3
+	TimeoutSec int      `mapstructure:"TimeoutSec"` // 单次请求超时，0 使用默认值 10 秒
 }
 
 type NascoreExtStru struct {
@@ -26,6 +80,8 @@ type NascoreExtStru struct {
 
 type ThirdPartyExtStru struct {
 	GitHubDownloadMirror string        `mapstructure:"GitHubDownloadMirror"`
+	ServiceLogDir        string        `mapstructure:"ServiceLogDir"`     // 第三方程序输出日志目录，空表示 TempFilePath 下的 logs/
+	SecretEnvPatterns    []string      `mapstructure:"SecretEnvPatterns"` // 名称匹配这些通配符的环境变量视为密钥，其值在日志与 API 输出中显示为 ***
 	Rclone               RcloneExtStru `mapstructure:"Rclone"`
 	DdnsGO               DdnsgoStru    `mapstructure:"DdnsGO"`
 	AdGuard              AdGuardStru   `mapstructure:"AdGuard"`
@@ -38,6 +94,8 @@ type OpenlistStru struct {
 	Version         string `mapstructure:"Version"`
 	BinPath         string `mapstructure:"BinPath"`
 	DataPath        string `mapstructure:"DataPath"`
+
+	Supervise SuperviseStru `mapstructure:"Supervise"`
 }
 
 func newOpenlistStru() OpenlistStru {
@@ -47,11 +105,14 @@ func newOpenlistStru() OpenlistStru {
 	} else {
 		path = "./ThirdPartyExt/openlist"
 	}
+	supervise := newDefaultSupervise()
+	supervise.After = []string{"rclone"} // openlist 常用于提供 rclone 挂载目录，先挂载再启动
 	return OpenlistStru{
 		Version:         "4.0.1",
 		BinPath:         path,
 		DataPath:        "./ThirdPartyExt/openlist_data",
 		AutoStartEnable: false,
+		Supervise:       supervise,
 	}
 }
 
@@ -60,6 +121,8 @@ type Caddy2Stru struct {
 	Version         string `mapstructure:"Version"`
 	BinPath         string `mapstructure:"BinPath"`
 	ConfigPath      string `mapstructure:"ConfigPath"`
+
+	Supervise SuperviseStru `mapstructure:"Supervise"`
 }
 
 func newCaddy2Config() Caddy2Stru {
@@ -74,6 +137,7 @@ func newCaddy2Config() Caddy2Stru {
 		BinPath:         path, // 实际解压到 caddy_2.10.0_linux_amd64/caddy
 		ConfigPath:      "./ThirdPartyExt/Caddyfile",
 		AutoStartEnable: false,
+		Supervise:       newDefaultSupervise(),
 	}
 }
 
@@ -82,8 +146,12 @@ type AcmeLegoStru struct {
 	Version                 string `mapstructure:"Version"`
 	BinPath                 string `mapstructure:"BinPath"`
 	AutoUpdateCheckInterval int    `mapstructure:"AutoUpdateCheckInterval"` // 单位是小时
+	AutoUpdateCheckCron     string `mapstructure:"AutoUpdateCheckCron"`     // cron 表达式，非空时代替 AutoUpdateCheckInterval
+	JobTimeoutSec           int    `mapstructure:"JobTimeoutSec"`           // 单次运行的超时时间，超时后结束 lego 进程
 	Command                 string `mapstructure:"Command"`
 	LEGO_PATH               string `mapstructure:"LEGO_PATH"`
+
+	Supervise SuperviseStru `mapstructure:"Supervise"` // Command 中以 &nascore 结尾的命令按此守护，lego 正常退出后不重启，always 按 on-failure 处理
 }
 
 func newAcmeLegoConfig() AcmeLegoStru {
@@ -101,9 +169,11 @@ set LEGO_SERVER=https://acme.zerossl.com/v2/DV90
 set LEGO_EAB_HMAC=your-hmac
 set LEGO_EAB_KID=your-kid
 ${BinPath} --accept-tos  --dns cloudflare  -d exp1.com -d *.exp1.com  --eab -k ec256 renew &nascore
+unset CF_DNS_API_TOKEN
 set ALICLOUD_ACCESS_KEY=abcdefghijklmnopqrstuvwx
 set ALICLOUD_SECRET_KEY=your-secret-key
 ${BinPath} --accept-tos  --dns alidns  -d exp2.com -d *.exp2.com --eab -k ec256 renew &nascore
+unset ALICLOUD_ACCESS_KEY ALICLOUD_SECRET_KEY
 set CF_DNS_API_TOKEN=your-api-token2
 ${BinPath} --accept-tos  --dns cloudflare  -d exp3.com -d '*.exp3.com' --eab -k ec256 renew &nascore
 `
@@ -120,20 +190,26 @@ export LEGO_SERVER=https://acme.zerossl.com/v2/DV90
 export LEGO_EAB_HMAC=your-hmac
 export LEGO_EAB_KID=your-kid
 ${BinPath} --accept-tos  --dns cloudflare  -d exp1.com -d *.exp1.com  --eab -k ec256 renew &nascore
+unset CF_DNS_API_TOKEN
 export ALICLOUD_ACCESS_KEY=abcdefghijklmnopqrstuvwx
 export ALICLOUD_SECRET_KEY=your-secret-key
 ${BinPath} --accept-tos  --dns alidns  -d exp2.com -d *.exp2.com --eab -k ec256 renew &nascore
+unset ALICLOUD_ACCESS_KEY ALICLOUD_SECRET_KEY
 export CF_DNS_API_TOKEN=your-api-token2
 ${BinPath} --accept-tos  --dns cloudflare  -d exp3.com -d '*.exp3.com' --eab -k ec256 renew &nascore
 `
 	}
+	supervise := newDefaultSupervise()
+	supervise.RestartPolicy = "never" // 续期失败时不立即重试，等待下一次计划任务，避免触发 CA 的频率限制
 	return AcmeLegoStru{
 		IsLegoAutoRenew:         false,
 		Version:                 "4.25.1",
 		BinPath:                 path,
 		LEGO_PATH:               "./ThirdPartyExt/lego_cert",
 		AutoUpdateCheckInterval: 24,
+		JobTimeoutSec:           600,
 		Command:                 command,
+		Supervise:               supervise,
 	}
 }
 
@@ -146,6 +222,8 @@ type AdGuardStru struct {
 	YouDohUrlSuffix            string `mapstructure:"YouDohUrlSuffix"`
 	AutoUpdateRulesEnable      bool   `mapstructure:"AutoUpdateRulesEnable"`
 	AutoUpdateRulesInterval    int    `mapstructure:"AutoUpdateRulesInterval"`
+	AutoUpdateRulesCron        string `mapstructure:"AutoUpdateRulesCron"` // cron 表达式，非空时代替 AutoUpdateRulesInterval
+	JobTimeoutSec              int    `mapstructure:"JobTimeoutSec"`       // 单次更新的超时时间
 }
 
 func newAdGuardConfig() AdGuardStru {
@@ -158,6 +236,7 @@ func newAdGuardConfig() AdGuardStru {
 		YouDohUrlSuffix:            "dns-query",
 		AutoUpdateRulesEnable:      false,
 		AutoUpdateRulesInterval:    48,
+		JobTimeoutSec:              300,
 	}
 }
 
@@ -165,9 +244,13 @@ type RcloneExtStru struct {
 	AutoMountEnable    bool   `mapstructure:"AutoMountEnable"`
 	AutoMountCommand   string `mapstructure:"AutoMountCommand"`
 	AutoUnMountCommand string `mapstructure:"AutoUnMountCommand"`
+	AutoReMountCron    string `mapstructure:"AutoReMountCron"` // 定时重新挂载的 cron 表达式，为空时不定时重新挂载
+	JobTimeoutSec      int    `mapstructure:"JobTimeoutSec"`   // 单次重新挂载的超时时间
 	Version            string `mapstructure:"Version"`
 	BinPath            string `mapstructure:"BinPath"`
 	ConfigFilePath     string `mapstructure:"ConfigFilePath"`
+
+	Supervise SuperviseStru `mapstructure:"Supervise"` // AutoMountCommand 中以 &nascore 结尾的挂载命令按此守护，挂载进程退出后先卸载再重启
 }
 type DdnsgoStru struct {
 	AutoStartEnable     bool   `mapstructure:"AutoStartEnable"`
@@ -176,6 +259,8 @@ type DdnsgoStru struct {
 	ConfigFilePath      string `mapstructure:"ConfigFilePath"`
 	BinPath             string `mapstructure:"BinPath"`
 	Version             string `mapstructure:"Version"`
+
+	Supervise SuperviseStru `mapstructure:"Supervise"`
 }
 
 func newDefaultDDSN() DdnsgoStru {
@@ -192,6 +277,80 @@ func newDefaultDDSN() DdnsgoStru {
 		ReverseproxyUrl:     "http://localhost:9876/",
 		BinPath:             path,
 		ConfigFilePath:      "./ThirdPartyExt/ddnsgo_config.yaml",
+		Supervise:           newDefaultSupervise(),
+	}
+}
+
+// SuperviseStru 第三方程序的守护配置
+type SuperviseStru struct {
+	RestartPolicy     string `mapstructure:"RestartPolicy"`     // never / on-failure / always
+	MaxRestarts       int    `mapstructure:"MaxRestarts"`       // 连续重启次数上限，0 表示不限制
+	BackoffInitialSec int    `mapstructure:"BackoffInitialSec"` // 第一次重启前的等待秒数，之后按指数增长
+	BackoffMaxSec     int    `mapstructure:"BackoffMaxSec"`     // 重启等待的上限秒数
+	StopGraceSec      int    `mapstructure:"StopGraceSec"`      // 停止时发送 SIGTERM 后等待的秒数，超时后 SIGKILL
+
+	LogMaxSizeMB  int `mapstructure:"LogMaxSizeMB"`  // 单个日志文件大小上限，超过后轮转
+	LogMaxAgeDays int `mapstructure:"LogMaxAgeDays"` // 轮转后的日志保留天数，0 表示不按时间清理
+	LogMaxBackups int `mapstructure:"LogMaxBackups"` // 轮转后的日志保留个数，0 表示不按个数清理
+	LogTailLines  int `mapstructure:"LogTailLines"`  // 内存中保留的最近日志行数，供后台查看
+
+	Liveness  ProbeStru `mapstructure:"Liveness"`  // 存活探测，连续失败后重启程序
+	Readiness ProbeStru `mapstructure:"Readiness"` // 就绪探测，未就绪时反向代理返回提示页
+
+	// 启动依赖。可以是服务名 rclone / ddnsgo / caddy2 / openlist / extensions，
+	// 也可以是条件 mount:<挂载点>（挂载就绪）或 file:<路径>（文件存在，例如证书）
+	After    []string `mapstructure:"After"`    // 仅约束启动顺序
+	Requires []string `mapstructure:"Requires"` // 依赖未满足前不启动
+
+	Limits LimitsStru `mapstructure:"Limits"` // 资源限制，仅 Linux 支持
+}
+
+// LimitsStru 第三方程序的资源限制，0 表示不限制或保持默认
+type LimitsStru struct {
+	MemoryMaxMB     int `mapstructure:"MemoryMaxMB"`     // cgroup v2 memory.max
+	CPUQuotaPercent int `mapstructure:"CPUQuotaPercent"` // cgroup v2 cpu.max，100 表示一个核心
+	NoFile          int `mapstructure:"NoFile"`          // RLIMIT_NOFILE
+	NProc           int `mapstructure:"NProc"`           // RLIMIT_NPROC
+	Nice            int `mapstructure:"Nice"`            // -20 ~ 19
+	IONiceClass     int `mapstructure:"IONiceClass"`     // 1 realtime / 2 best-effort / 3 idle
+	IONiceLevel     int `mapstructure:"IONiceLevel"`     // 0 ~ 7，class 为 1 或 2 时有效
+	Uid             int `mapstructure:"Uid"`             // 以指定用户运行，需要 nascore 以 root 运行
+	Gid             int `mapstructure:"Gid"`             // 为 0 时使用 Uid 对应用户的主组
+}
+
+// ProbeStru 健康探测配置，Type 为空表示不探测
+type ProbeStru struct {
+	Type             string `mapstructure:"Type"`             // http / tcp / unix / exec
+	Target           string `mapstructure:"Target"`           // http 为 URL，tcp 为 host:port，unix 为 socket 路径，exec 为命令行
+	InitialDelaySec  int    `mapstructure:"InitialDelaySec"`  // 程序启动后等待多久开始探测
+	IntervalSec      int    `mapstructure:"IntervalSec"`      // 探测间隔
+	TimeoutSec       int    `mapstructure:"TimeoutSec"`       // 单次探测超时
+	FailureThreshold int    `mapstructure:"FailureThreshold"` // 连续失败多少次判定为失败
+}
+
+func newDefaultProbe() ProbeStru {
+	return ProbeStru{
+		Type:             "",
+		InitialDelaySec:  5,
+		IntervalSec:      10,
+		TimeoutSec:       3,
+		FailureThreshold: 3,
+	}
+}
+
+func newDefaultSupervise() SuperviseStru {
+	return SuperviseStru{
+		RestartPolicy:     "on-failure",
+		MaxRestarts:       10,
+		BackoffInitialSec: 1,
+		BackoffMaxSec:     300,
+		StopGraceSec:      10,
+		LogMaxSizeMB:      10,
+		LogMaxAgeDays:     7,
+		LogMaxBackups:     3,
+		LogTailLines:      500,
+		Liveness:          newDefaultProbe(),
+		Readiness:         newDefaultProbe(),
 	}
 }
 
@@ -209,6 +368,7 @@ type ServerStru struct {
 	ApiEnable         bool   `mapstructure:"ApiEnable"`
 	WebDavEnable      bool   `mapstructure:"WebDavEnable"`
 	TempFilePath      string `mapstructure:"TempFilePath"`
+	CronTimeZone      string `mapstructure:"CronTimeZone"` // 计划任务使用的时区，例如 Asia/Shanghai，为空时使用系统时区
 
 	DefaultStaticFileServicePrefix string `mapstructure:"DefaultStaticFileService"`
 	DefaultStaticFileServiceEnable bool   `mapstructure:"DefaultStaticFileServiceEnable"`
@@ -276,6 +436,8 @@ type VodCacheStru struct {
 type VodSubscriptionStru struct {
 	DefaultSelectedAPISite []string `mapstructure:"DefaultSelectedAPISite"`
 	IntervalHour           int      `mapstructure:"IntervalHour"`
+	Cron                   string   `mapstructure:"Cron"`          // cron 表达式，非空时代替 IntervalHour
+	JobTimeoutSec          int      `mapstructure:"JobTimeoutSec"` // 单次刷新的超时时间
 	Urls                   []string `mapstructure:"Urls"`
 }
 type LinksStru struct {
@@ -311,6 +473,7 @@ func newNascoreExtStru() NascoreExtStru {
 			VodSubscription: VodSubscriptionStru{
 				DefaultSelectedAPISite: []string{"tyyszy", "bfzy", "dyttzy", "ruyi"},
 				IntervalHour:           22,
+				JobTimeoutSec:          120,
 				Urls: []string{
 					"https://raw.githubusercontent.com/nas-core/nascore-website/refs/heads/main/docs/.vuepress/public/nascore_tv/subscription_example1.toml",
 					"https://raw.githubusercontent.com/nas-core/nascore-website/refs/heads/main/docs/.vuepress/public/nascore_tv/subscription_example2.toml",
@@ -325,10 +488,8 @@ func NewDefaultConfig() *SysCfg {
 	cfg := &SysCfg{
 		Server: newDefaultServerConfig(),
 		JWT:    newDefaultJWTConfig(),
-		Secret: SecretStru{
-			JwtSecret:      GenerateStr(1),
-			Sha256HashSalt: GenerateStr(2),
-			AESkey:         GenerateStr(3),
+		Secret: SecretStru{ // 密钥为空，加载时从密钥文件读取或随机生成
+			RotateLegacy: []string{},
 		},
 		Limit: LimitStru{
 			MaxFailedLoginsIpMap:       1000,
@@ -338,6 +499,7 @@ func NewDefaultConfig() *SysCfg {
 		WebUICdnPrefix: "https://cdn.jsdmirror.com/gh/nas-core/nascore_static@main/",
 		ThirdPartyExt: ThirdPartyExtStru{
 			GitHubDownloadMirror: "https://github.akams.cn/",
+			SecretEnvPatterns:    []string{"*TOKEN*", "*SECRET*", "*KEY*", "*HMAC*", "*PASSWORD*"},
 			Openlist:             newOpenlistStru(),
 			DdnsGO:               newDefaultDDSN(),
 			Rclone:               newDefaultRclone(),
@@ -346,6 +508,17 @@ func NewDefaultConfig() *SysCfg {
 			Caddy2:               newCaddy2Config(),
 		},
 		NascoreExt: newNascoreExtStru(),
+		Events: EventsStru{
+			Webhooks: []WebhookStru{},
+		},
+		Notify: NotifyStru{
+			Channels:           []NotifyChannelStru{},
+			Routes:             []NotifyRouteStru{},
+			RateLimitPerHour:   20,
+			DedupWindowMin:     60,
+			CertExpiryWarnDays: 14,
+			CertCheckCron:      "0 9 * * *",
+		},
 	}
 	// 统一补全目录路径结尾
 	cfg.Server.TempFilePath = EnsureDirPathSuffix(cfg.Server.TempFilePath)
@@ -357,9 +530,13 @@ func NewDefaultConfig() *SysCfg {
 // 恢复 SecretStru 结构体
 // SecretStru 密钥配置
 type SecretStru struct {
-	JwtSecret      string `mapstructure:"JwtSecret"`
-	Sha256HashSalt string `mapstructure:"Sha256HashSalt"`
-	AESkey         string `mapstructure:"AESkey"`
+	JwtSecret      string   `mapstructure:"JwtSecret"`
+	Sha256HashSalt string   `mapstructure:"Sha256HashSalt"`
+	AESkey         string   `mapstructure:"AESkey"`
+	SecretsFile    string   `mapstructure:"SecretsFile"`  // 自动生成的密钥保存在此文件（权限 0600），为空时为配置文件同目录下的 nascore_secrets.toml
+	RotateLegacy   []string `mapstructure:"RotateLegacy"` // 要替换为随机值的旧版按主机名推导的密钥，只支持 ["JwtSecret"]，替换后旧令牌立即失效
+
+	fromFile []string // 取自密钥文件或自动生成的密钥名称，见 FromSecretsFile
 }
 
 // 恢复 newDefaultRclone 函数
@@ -399,5 +576,7 @@ fusermount3 -u /home/yh/oss_qd &nascore
 		AutoMountEnable:    false,
 		AutoMountCommand:   autoMountCommand,
 		AutoUnMountCommand: autoUnMountCommand,
+		JobTimeoutSec:      120,
+		Supervise:          newDefaultSupervise(),
 	}
 }
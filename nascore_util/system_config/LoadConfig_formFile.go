@@ -1,23 +1,30 @@
 package system_config
 
 import (
+	"errors"
 	"log"
+	"os"
 
 	"github.com/spf13/viper"
 )
 
-// LoadConfig 从文件加载配置
+// LoadConfig 从文件加载配置，文件不存在时使用默认配置。文件无法解析或配置存在致命问题（见 Validate）时返回错误，
+// 其中致命问题为 *ValidationError：启动时应拒绝运行（见 MustLoadConfig），热重载时应保留旧配置
 func LoadConfig(configPath string) (*SysCfg, error) {
 	viper.SetConfigFile(configPath)
 	viper.SetConfigType("toml")
 
+	var errs []error
 	if err := viper.ReadInConfig(); err != nil {
 		log.Println("viper.ReadInConfig file failed: ", err)
+		if _, statErr := os.Stat(configPath); statErr == nil {
+			return NewDefaultConfig(), err // 文件存在但无法解析，viper 中可能还是上一次的内容，不再继续
+		}
 	}
 	config := NewDefaultConfig() // 初始化 config 为指针类型
-	err := viper.Unmarshal(config)
-	if err != nil {
+	if err := viper.Unmarshal(config); err != nil {
 		log.Println("viper.Unmarshal failed: ", err)
+		errs = append(errs, err)
 	}
 
 	// 统一补全目录路径结尾
@@ -25,18 +32,25 @@ func LoadConfig(configPath string) (*SysCfg, error) {
 	config.ThirdPartyExt.Openlist.DataPath = EnsureDirPathSuffix(config.ThirdPartyExt.Openlist.DataPath)
 	config.ThirdPartyExt.AcmeLego.LEGO_PATH = EnsureDirPathSuffix(config.ThirdPartyExt.AcmeLego.LEGO_PATH)
 
-	if config.Secret.JwtSecret == "" {
-		config.Secret.JwtSecret = GenerateStr(1)
-		log.Println("config.Secret.JwtSecret is empty set :", config.Secret.JwtSecret)
+	_, statErr := os.Stat(configPath)
+	if err := resolveSecrets(config, configPath, statErr == nil); err != nil {
+		log.Println("resolve secrets failed: ", err)
+		errs = append(errs, err)
 	}
-	if config.Secret.Sha256HashSalt == "" {
-		config.Secret.Sha256HashSalt = GenerateStr(2)
-		log.Println("config.Secret.Sha256HashSalt is empty set :", config.Secret.Sha256HashSalt)
+
+	errs = append(errs, config.Validate().Err())
+	return config, errors.Join(errs...)
+}
+
+// MustLoadConfig 启动时加载配置并发布为 Current，打印全部配置问题，存在致命问题时拒绝启动
+func MustLoadConfig(configPath string) *SysCfg {
+	config, err := LoadConfig(configPath)
+	for _, issue := range config.Validate() {
+		log.Println("config", issue)
 	}
-	if config.Secret.AESkey == "" {
-		config.Secret.AESkey = GenerateStr(3)
-		log.Println("config.Secret.AESkey is empty set :", config.Secret.AESkey)
+	if err != nil {
+		log.Fatalln("refuse to start with invalid config", configPath, ":", err)
 	}
-
-	return config, err
+	Store(config)
+	return config
 }
@@ -0,0 +1,30 @@
+package system_config
+
+import "sync/atomic"
+
+// current 最新发布的配置。发布后的 SysCfg 是只读快照，热重载时整体替换，不再就地修改
+var current atomic.Pointer[SysCfg]
+
+// Store 发布新的配置快照
+func Store(cfg *SysCfg) {
+	current.Store(cfg)
+}
+
+// StoreInitial 尚未发布过配置时把启动时的配置作为第一份快照发布，之后同样不能再修改它
+func StoreInitial(cfg *SysCfg) {
+	current.CompareAndSwap(nil, cfg)
+}
+
+// Current 返回最新发布的配置，尚未发布时返回 nil
+func Current() *SysCfg {
+	return current.Load()
+}
+
+// CurrentOr 返回最新发布的配置，尚未发布时返回 fallback。
+// handler 在每个请求开始时、循环在每一轮开始时取一次，之后一直使用同一份快照，不会读到重载到一半的配置
+func CurrentOr(fallback *SysCfg) *SysCfg {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return fallback
+}
@@ -0,0 +1,102 @@
+package system_config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ConfigChange 一个发生变化的字段，Path 与 ConfigIssue 相同为 TOML 中的路径。
+// Old、New 可能包含密钥，只用于判断，不要直接输出到日志或事件
+type ConfigChange struct {
+	Path string
+	Old  any
+	New  any
+}
+
+// ConfigDiff 两份配置之间的差异，按字段在结构体中的顺序排列
+type ConfigDiff []ConfigChange
+
+// Diff 逐字段比较两份配置。结构体与切片元素展开到叶子字段，
+// 切片长度变化时超出部分的元素整体作为一项（Old 或 New 为 nil）
+func Diff(old, new *SysCfg) ConfigDiff {
+	var d ConfigDiff
+	diffValue(&d, "", reflect.ValueOf(*old), reflect.ValueOf(*new))
+	return d
+}
+
+func diffValue(d *ConfigDiff, path string, a, b reflect.Value) {
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Tag.Get("mapstructure")
+			if name == "-" { // 不来自配置文件
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValue(d, name, a.Field(i), b.Field(i))
+		}
+	case reflect.Slice:
+		if a.Type().Elem().Kind() != reflect.Struct { // []string 等作为一个整体比较
+			if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+				*d = append(*d, ConfigChange{Path: path, Old: a.Interface(), New: b.Interface()})
+			}
+			return
+		}
+		for i := 0; i < max(a.Len(), b.Len()); i++ {
+			elem := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= a.Len():
+				*d = append(*d, ConfigChange{Path: elem, New: b.Index(i).Interface()})
+			case i >= b.Len():
+				*d = append(*d, ConfigChange{Path: elem, Old: a.Index(i).Interface()})
+			default:
+				diffValue(d, elem, a.Index(i), b.Index(i))
+			}
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*d = append(*d, ConfigChange{Path: path, Old: a.Interface(), New: b.Interface()})
+		}
+	}
+}
+
+// Paths 返回所有发生变化的路径
+func (d ConfigDiff) Paths() []string {
+	paths := make([]string, len(d))
+	for i, c := range d {
+		paths[i] = c.Path
+	}
+	return paths
+}
+
+// Under 返回 prefix 本身或其下字段的变化，prefix 例如 ThirdPartyExt.Caddy2
+func (d ConfigDiff) Under(prefix string) ConfigDiff {
+	var sub ConfigDiff
+	for _, c := range d {
+		if c.Path == prefix || strings.HasPrefix(c.Path, prefix+".") || strings.HasPrefix(c.Path, prefix+"[") {
+			sub = append(sub, c)
+		}
+	}
+	return sub
+}
+
+// Changed 任一 prefix 本身或其下是否有字段发生变化
+func (d ConfigDiff) Changed(prefixes ...string) bool {
+	for _, p := range prefixes {
+		if len(d.Under(p)) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package system_config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*SysCfg)
+		want   []string
+	}{
+		{"unchanged", func(c *SysCfg) {}, nil},
+		{"leaf field uses the TOML name", func(c *SysCfg) { c.Server.HttpPort++ }, []string{"Server.httpPort"}},
+		{"nested struct", func(c *SysCfg) {
+			c.ThirdPartyExt.Caddy2.Supervise.MaxRestarts = 7
+			c.ThirdPartyExt.Caddy2.Supervise.Limits.Nice = 5
+		}, []string{"ThirdPartyExt.Caddy2.Supervise.MaxRestarts", "ThirdPartyExt.Caddy2.Supervise.Limits.Nice"}},
+		{"string slice as a whole", func(c *SysCfg) { c.ThirdPartyExt.SecretEnvPatterns = []string{"*TOKEN*"} },
+			[]string{"ThirdPartyExt.SecretEnvPatterns"}},
+		{"appended struct element", func(c *SysCfg) { c.Events.Webhooks = append(c.Events.Webhooks, WebhookStru{Name: "a"}) },
+			[]string{"Events.Webhooks[0]"}},
+	}
+	for _, tt := range tests {
+		old, new := NewDefaultConfig(), NewDefaultConfig()
+		tt.mutate(new)
+		if got := Diff(old, new).Paths(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Diff paths = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffSliceElements(t *testing.T) {
+	old, new := NewDefaultConfig(), NewDefaultConfig()
+	old.Notify.Channels = []NotifyChannelStru{{Name: "a"}, {Name: "b"}}
+	new.Notify.Channels = []NotifyChannelStru{{Name: "a", Enable: true}}
+	d := Diff(old, new)
+	if got, want := d.Paths(), []string{"Notify.Channels[0].Enable", "Notify.Channels[1]"}; !slices.Equal(got, want) {
+		t.Fatalf("Diff paths = %v, want %v", got, want)
+	}
+	if removed := d[1]; removed.New != nil || removed.Old.(NotifyChannelStru).Name != "b" {
+		t.Fatalf("removed element = %+v", removed)
+	}
+}
+
+func TestDiffChanged(t *testing.T) {
+	d := ConfigDiff{
+		{Path: "Server.httpPort"},
+		{Path: "ThirdPartyExt.Caddy2.Supervise.MaxRestarts"},
+		{Path: "Events.Webhooks[0]"},
+	}
+	tests := []struct {
+		prefixes []string
+		want     bool
+	}{
+		{[]string{"Server.httpPort"}, true},
+		{[]string{"Server"}, true},
+		{[]string{"Server.http"}, false}, // 只按完整的路径段匹配
+		{[]string{"ThirdPartyExt.Caddy2"}, true},
+		{[]string{"ThirdPartyExt.Caddy"}, false},
+		{[]string{"Events.Webhooks"}, true},
+		{[]string{"Events"}, true},
+		{[]string{"Notify", "ThirdPartyExt.Openlist"}, false},
+		{[]string{"Notify", "Events"}, true},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := d.Changed(tt.prefixes...); got != tt.want {
+			t.Errorf("Changed(%v) = %v, want %v", tt.prefixes, got, tt.want)
+		}
+	}
+	if got := d.Under("ThirdPartyExt").Paths(); !slices.Equal(got, []string{"ThirdPartyExt.Caddy2.Supervise.MaxRestarts"}) {
+		t.Errorf("Under(ThirdPartyExt) = %v", got)
+	}
+}
@@ -3,32 +3,33 @@ package system_config
 import (
 	"crypto/md5"
 	"fmt"
-	"io"
-	"os"
 	"runtime"
 	"strings"
 )
 
+// GenerateStr 旧版本按主机名推导密钥的方式，知道主机名就能算出，只用于识别旧配置中的这些值。
+//
+// Deprecated: 使用 RandomSecret
 func GenerateStr(typeInt int) string {
-	baseStr, err := os.Hostname()
-	tmpHash := "nascore.eu.org"
-
-	if err != nil {
-		baseStr = tmpHash
+	names := []string{1: SecretJwt, 2: SecretSalt, 3: SecretAES}
+	if typeInt < 1 || typeInt >= len(names) {
+		return legacyHostname()
 	}
-	h := md5.New()
-	switch typeInt {
-	case 1:
-		io.WriteString(h, baseStr)
-		baseStr = fmt.Sprintf("%x", h.Sum(nil))
-	case 2:
-		io.WriteString(h, baseStr+tmpHash)
-		baseStr = fmt.Sprintf("%x", h.Sum(nil))
-	case 3:
-		io.WriteString(h, baseStr+tmpHash+"https://api.nascore.eu.org")
-		baseStr = fmt.Sprintf("%x", h.Sum(nil))
+	return legacySecret(names[typeInt], legacyHostname())
+}
+
+// legacySecret 按 GenerateStr 的方式由 host 推导 name 对应的密钥
+func legacySecret(name, host string) string {
+	const tmpHash = "nascore.eu.org"
+	switch name {
+	case SecretJwt:
+		return fmt.Sprintf("%x", md5.Sum([]byte(host)))
+	case SecretSalt:
+		return fmt.Sprintf("%x", md5.Sum([]byte(host+tmpHash)))
+	case SecretAES:
+		return fmt.Sprintf("%x", md5.Sum([]byte(host+tmpHash+"https://api.nascore.eu.org")))
 	}
-	return baseStr
+	return host
 }
 
 // EnsureDirPathSuffix 补全目录路径结尾的 / 或 \
@@ -0,0 +1,213 @@
+package system_config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// 各密钥的名称。Secret.RotateLegacy 只支持 SecretJwt，Salt 与 AES 没有迁移方式，只能手动修改
+const (
+	SecretJwt     = "JwtSecret"
+	SecretSalt    = "Sha256HashSalt"
+	SecretAES     = "AESkey"
+	SecretWebhook = "WebhookSecret" // 位于 Events 而不是 Secret
+)
+
+const defaultSecretsFileName = "nascore_secrets.toml"
+
+// secretsFile 密钥文件的内容，只保存自动生成或轮换出的密钥，nascore.toml 中显式配置的密钥不会写入
+type secretsFile struct {
+	JwtSecret      string `toml:"JwtSecret"`
+	Sha256HashSalt string `toml:"Sha256HashSalt"`
+	AESkey         string `toml:"AESkey"`
+	WebhookSecret  string `toml:"WebhookSecret"`
+}
+
+var (
+	secretsMu      sync.Mutex
+	unsavedSecrets = make(map[string]secretsFile) // 无法写入密钥文件时生成的密钥，保证热重载前后一致
+)
+
+// RandomSecret 返回 n 字节 crypto/rand 随机数的十六进制字符串
+func RandomSecret(n int) string {
+	b := make([]byte, n)
+	rand.Read(b) // 失败时直接终止进程，不会返回错误
+	return hex.EncodeToString(b)
+}
+
+// FromSecretsFile 密钥是否取自密钥文件（包括本次自动生成的），而不是 nascore.toml 中显式配置的。
+// 导出配置时应清空这些密钥，避免把它们写入权限较宽的配置文件
+func (s SecretStru) FromSecretsFile(name string) bool {
+	return slices.Contains(s.fromFile, name)
+}
+
+// SecretsFilePath 密钥文件的路径，Secret.SecretsFile 为空时为配置文件同目录下的 nascore_secrets.toml
+func SecretsFilePath(cfg *SysCfg, configPath string) string {
+	if cfg.Secret.SecretsFile != "" {
+		return cfg.Secret.SecretsFile
+	}
+	return filepath.Join(filepath.Dir(configPath), defaultSecretsFileName)
+}
+
+func legacyHostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "nascore.eu.org"
+	}
+	return host
+}
+
+// IsLegacySecret 密钥是否为旧版本按主机名推导出的值（见 GenerateStr），知道主机名就能算出
+func IsLegacySecret(name, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, host := range []string{legacyHostname(), "nascore.eu.org"} {
+		if value == legacySecret(name, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecrets 补全 Secret 中的密钥。nascore.toml 中显式配置的优先，其次为密钥文件，都没有时：
+// 已有的安装沿用按主机名推导的旧值，避免升级后登录状态与密码失效，由 Validate 提示轮换；新安装生成随机密钥。
+// 列在 Secret.RotateLegacy 中的旧 JwtSecret 会被替换为随机密钥。轮换没有宽限期，用旧密钥签发的令牌立即失效，需要重新登录。
+// Sha256HashSalt 与 AESkey 替换后已有的密码哈希与加密数据都无法使用，不会自动轮换，由 Validate 提示
+func resolveSecrets(cfg *SysCfg, configPath string, configExists bool) error {
+	path := SecretsFilePath(cfg, configPath)
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	f, err := readSecretsFile(path)
+	if err != nil {
+		return err
+	}
+	s := &cfg.Secret
+	s.fromFile = nil
+	dirty := false
+	fields := []struct {
+		name     string
+		cfg      *string
+		file     *string
+		byteSize int
+	}{
+		{SecretJwt, &s.JwtSecret, &f.JwtSecret, 32},
+		{SecretSalt, &s.Sha256HashSalt, &f.Sha256HashSalt, 32},
+		{SecretAES, &s.AESkey, &f.AESkey, 16}, // 与旧版相同为 32 个字符
+	}
+	for _, fd := range fields {
+		rotate := fd.name == SecretJwt && slices.Contains(s.RotateLegacy, fd.name)
+		fromConfig := false
+		var picked, legacy string
+		for i, v := range []string{*fd.cfg, *fd.file} {
+			if v == "" {
+				continue
+			}
+			if rotate && IsLegacySecret(fd.name, v) {
+				legacy = v
+				continue
+			}
+			picked, fromConfig = v, i == 0
+			break
+		}
+		if picked == "" && legacy == "" && configExists {
+			legacy = legacySecret(fd.name, legacyHostname())
+			if !rotate {
+				picked = legacy
+			}
+		}
+		if picked == "" {
+			picked = RandomSecret(fd.byteSize)
+			if legacy != "" {
+				log.Printf("Secret.%s: replaced the hostname derived value with a random one, existing login tokens are no longer valid", fd.name)
+			} else {
+				log.Printf("Secret.%s: generated a random value", fd.name)
+			}
+		}
+		*fd.cfg = picked
+		if !fromConfig {
+			s.fromFile = append(s.fromFile, fd.name)
+			if *fd.file != picked {
+				*fd.file = picked
+				dirty = true
+			}
+		}
+	}
+
+	// webhook 签名密钥没有按主机名推导的旧值，未配置时直接生成。接收方需要改用它校验签名
+	if cfg.Events.WebhookSecret == "" {
+		if f.WebhookSecret == "" {
+			f.WebhookSecret = RandomSecret(32)
+			dirty = true
+			log.Printf("Events.WebhookSecret: generated a random value, webhook receivers verify signatures with it (saved in %s)", path)
+		}
+		cfg.Events.WebhookSecret = f.WebhookSecret
+		s.fromFile = append(s.fromFile, SecretWebhook)
+	}
+
+	if dirty {
+		if err := writeSecretsFile(path, f); err != nil {
+			unsavedSecrets[path] = f
+			log.Printf("save secrets to %s failed: %v, they will change after restart unless set in [Secret] of the config file", path, err)
+		} else {
+			delete(unsavedSecrets, path)
+		}
+	}
+	return nil
+}
+
+// readSecretsFile 读取密钥文件，文件不存在时返回本进程中未能保存的密钥（可能为空）。调用方需持有 secretsMu
+func readSecretsFile(path string) (secretsFile, error) {
+	var f secretsFile
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return unsavedSecrets[path], nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("read secrets file %s: %w", path, err)
+	}
+	if err := toml.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("parse secrets file %s: %w", path, err) // 不重新生成，以免覆盖仍在使用的密钥
+	}
+	if fi, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		log.Printf("secrets file %s is accessible by other users (%v), changing to 0600", path, fi.Mode().Perm())
+		os.Chmod(path, 0o600)
+	}
+	return f, nil
+}
+
+// writeSecretsFile 先写入同目录下的临时文件再改名，权限为 0600
+func writeSecretsFile(path string, f secretsFile) error {
+	data, err := toml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	data = append([]byte("# generated by nascore, keep this file private\n"), data...)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil && runtime.GOOS != "windows" {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,483 @@
+package system_config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nas-core/nascore/nascore_util/cmdline"
+	"github.com/nas-core/nascore/nascore_util/cronspec"
+)
+
+// Severity 配置问题的严重程度
+type Severity string
+
+const (
+	SeverityFatal   Severity = "fatal"   // 按此配置无法正常运行，启动时拒绝，热重载时保留旧配置
+	SeverityWarning Severity = "warning" // 可以运行，但多半不是期望的行为
+)
+
+// ConfigIssue 配置中的一个问题，Path 为 TOML 中的路径，例如 Server.httpPort、Notify.Channels[0].Url
+type ConfigIssue struct {
+	Path       string   `json:"path"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+func (i ConfigIssue) String() string {
+	s := fmt.Sprintf("[%s] %s: %s", i.Severity, i.Path, i.Message)
+	if i.Suggestion != "" {
+		s += " (" + i.Suggestion + ")"
+	}
+	return s
+}
+
+// ConfigIssues Validate 的结果
+type ConfigIssues []ConfigIssue
+
+// Fatal 返回其中的致命问题
+func (issues ConfigIssues) Fatal() ConfigIssues {
+	var fatal ConfigIssues
+	for _, i := range issues {
+		if i.Severity == SeverityFatal {
+			fatal = append(fatal, i)
+		}
+	}
+	return fatal
+}
+
+// Err 存在致命问题时返回 *ValidationError
+func (issues ConfigIssues) Err() error {
+	if len(issues.Fatal()) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: issues}
+}
+
+// ValidationError 配置存在致命问题，Issues 包含全部问题
+type ValidationError struct {
+	Issues ConfigIssues
+}
+
+func (e *ValidationError) Error() string {
+	fatal := e.Issues.Fatal()
+	lines := make([]string, len(fatal))
+	for i, issue := range fatal {
+		lines[i] = issue.String()
+	}
+	return fmt.Sprintf("invalid config, %d fatal issue(s): %s", len(fatal), strings.Join(lines, "; "))
+}
+
+// validator 收集问题
+type validator struct {
+	issues ConfigIssues
+}
+
+func (v *validator) fatal(path, suggestion, format string, args ...any) {
+	v.issues = append(v.issues, ConfigIssue{Path: path, Severity: SeverityFatal, Message: fmt.Sprintf(format, args...), Suggestion: suggestion})
+}
+
+func (v *validator) warn(path, suggestion, format string, args ...any) {
+	v.issues = append(v.issues, ConfigIssue{Path: path, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...), Suggestion: suggestion})
+}
+
+// Validate 检查配置，返回全部问题。只检查值本身，不访问网络
+func (cfg *SysCfg) Validate() ConfigIssues {
+	v := &validator{}
+	v.server(cfg.Server)
+	v.jwt(cfg.JWT)
+	v.secret(cfg.Secret)
+	v.limit(cfg.Limit)
+	if cfg.WebUICdnPrefix != "" {
+		v.httpURL("WebUICdnPrefix", cfg.WebUICdnPrefix, false)
+	}
+	v.nascoreExt(cfg.NascoreExt)
+	v.thirdPartyExt(cfg.ThirdPartyExt)
+	v.events(cfg.Events)
+	v.notify(cfg.Notify)
+	return v.issues
+}
+
+func (v *validator) port(path string, port int) {
+	if port < 1 || port > 65535 {
+		v.fatal(path, "use a port between 1 and 65535", "invalid port %d", port)
+	}
+}
+
+// httpURL 检查 http(s) 地址，required 为 true 时问题是致命的
+func (v *validator) httpURL(path, raw string, required bool) {
+	report := v.warn
+	if required {
+		report = v.fatal
+	}
+	if raw == "" {
+		report(path, "set a URL such as http://127.0.0.1:3000/", "URL is empty")
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		report(path, "use a URL such as http://127.0.0.1:3000/", "cannot parse URL: %v", err)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		report(path, "use a URL with http:// or https:// and a host", "invalid URL %q", raw)
+	}
+}
+
+func (v *validator) cron(path, spec string) {
+	if spec == "" {
+		return
+	}
+	if _, err := cronspec.Parse(spec, time.Local); err != nil {
+		v.fatal(path, `use a cron expression such as "0 3 * * *" or "@every 12h"`, "invalid cron expression: %v", err)
+	}
+}
+
+// interval 任务启用但既没有 cron 也没有正数间隔时，任务不会运行
+func (v *validator) interval(path string, hours int, cronSpec, cronPath string) {
+	if cronSpec == "" && hours <= 0 {
+		v.warn(path, "set an interval of at least 1 hour or set "+cronPath, "interval is %d, the job never runs", hours)
+	}
+}
+
+func (v *validator) nonNegative(path string, n int64) {
+	if n < 0 {
+		v.warn(path, "use 0 or a positive number", "negative value %d", n)
+	}
+}
+
+func (v *validator) fileExists(path, file, suggestion string) {
+	if file == "" {
+		v.fatal(path, suggestion, "path is empty")
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		v.fatal(path, suggestion, "%v", err)
+	}
+}
+
+// urlPrefix 路由前缀需要以 / 开头并以 / 结尾
+func (v *validator) urlPrefix(path, prefix string) {
+	if !strings.HasPrefix(prefix, "/") || !strings.HasSuffix(prefix, "/") {
+		v.warn(path, fmt.Sprintf("use a prefix like %q", "/"+strings.Trim(prefix, "/")+"/"), "prefix %q should start and end with /", prefix)
+	}
+}
+
+func (v *validator) server(s ServerStru) {
+	v.port("Server.httpPort", s.HttpPort)
+	if s.HttpsEnable {
+		v.port("Server.httpsPort", s.HttpsPort)
+		if s.HttpsPort == s.HttpPort {
+			v.fatal("Server.httpsPort", "use a port different from httpPort", "httpsPort and httpPort are both %d", s.HttpPort)
+		}
+		v.fileExists("Server.tlscert", s.TlsCert, "point tlscert to a PEM certificate or set HttpsEnable = false")
+		v.fileExists("Server.tlskey", s.TlsKey, "point tlskey to a PEM private key or set HttpsEnable = false")
+	}
+	if s.TempFilePath == "" {
+		v.fatal("Server.TempFilePath", "set a writable directory such as /tmp/nascore_socket/", "path is empty")
+	}
+	if s.CronTimeZone != "" {
+		if _, err := time.LoadLocation(s.CronTimeZone); err != nil {
+			v.warn("Server.CronTimeZone", "use an IANA time zone such as Asia/Shanghai, the system time zone is used meanwhile", "%v", err)
+		}
+	}
+	if s.WebuiAndApiEnable {
+		v.urlPrefix("Server.PrefixWebUI", s.WebUIPrefix)
+	}
+	if s.DefaultStaticFileServiceEnable {
+		v.urlPrefix("Server.DefaultStaticFileService", s.DefaultStaticFileServicePrefix)
+		if _, err := os.Stat(s.DefaultStaticFileServiceRoot); err != nil {
+			v.warn("Server.DefaultStaticFileServiceRoot", "create the directory or set DefaultStaticFileServiceEnable = false", "%v", err)
+		}
+	}
+}
+
+func (v *validator) jwt(j JwtStru) {
+	if j.UserAccessTokenExpires <= 0 {
+		v.fatal("JWT.user_access_token_expires", "use a lifetime in seconds, e.g. 2592000", "invalid lifetime %d", j.UserAccessTokenExpires)
+	}
+	if j.UserRefreshTokenExpires <= 0 {
+		v.fatal("JWT.user_refresh_token_expires", "use a lifetime in seconds, e.g. 7776000", "invalid lifetime %d", j.UserRefreshTokenExpires)
+	} else if j.UserRefreshTokenExpires < j.UserAccessTokenExpires {
+		v.warn("JWT.user_refresh_token_expires", "make the refresh token live longer than the access token", "refresh token expires before the access token")
+	}
+}
+
+func (v *validator) secret(s SecretStru) {
+	const forged = "derived from the hostname, anyone who knows the hostname can compute it"
+	if IsLegacySecret(SecretJwt, s.JwtSecret) {
+		v.warn("Secret.JwtSecret", `add "JwtSecret" to Secret.RotateLegacy, users have to log in again after the rotation`, "%s and forge login tokens", forged)
+	}
+	if IsLegacySecret(SecretSalt, s.Sha256HashSalt) {
+		v.warn("Secret.Sha256HashSalt", "set a new value in [Secret] and set all passwords again at the same time", "%s", forged)
+	}
+	if IsLegacySecret(SecretAES, s.AESkey) {
+		v.warn("Secret.AESkey", "decrypt data encrypted with the old key first, then set a new value in [Secret]", "%s", forged)
+	}
+	for i, name := range s.RotateLegacy {
+		switch name {
+		case SecretJwt:
+		case SecretSalt, SecretAES:
+			v.warn(fmt.Sprintf("Secret.RotateLegacy[%d]", i), "remove it and change the value in [Secret] by hand", "%s cannot be rotated automatically, existing password hashes or encrypted data would become unusable", name)
+		default:
+			v.warn(fmt.Sprintf("Secret.RotateLegacy[%d]", i), "only JwtSecret can be rotated", "unknown secret %q", name)
+		}
+	}
+}
+
+func (v *validator) limit(l LimitStru) {
+	v.nonNegative("Limit.OnlineEditMaxSizeKB", l.OnlineEditMaxSizeKB)
+	if l.MaxFailedLoginsIpMap <= 0 {
+		v.warn("Limit.MaxFailedLoginsIpMap", "use a positive number such as 1000", "failed logins are not tracked with value %d", l.MaxFailedLoginsIpMap)
+	}
+	v.nonNegative("Limit.MaxFailedLoginSleepTimeSec", int64(l.MaxFailedLoginSleepTimeSec))
+}
+
+func (v *validator) nascoreExt(n NascoreExtStru) {
+	sub := n.Vod.VodSubscription
+	const p = "NascoreExt.Vod.VodSubscription"
+	for i, u := range sub.Urls {
+		v.httpURL(fmt.Sprintf("%s.Urls[%d]", p, i), u, false)
+	}
+	v.cron(p+".Cron", sub.Cron)
+	if len(sub.Urls) > 0 {
+		v.interval(p+".IntervalHour", sub.IntervalHour, sub.Cron, p+".Cron")
+	}
+	v.nonNegative(p+".JobTimeoutSec", int64(sub.JobTimeoutSec))
+}
+
+func (v *validator) thirdPartyExt(t ThirdPartyExtStru) {
+	if t.GitHubDownloadMirror != "" {
+		v.httpURL("ThirdPartyExt.GitHubDownloadMirror", t.GitHubDownloadMirror, false)
+	}
+	for i, p := range t.SecretEnvPatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			v.warn(fmt.Sprintf("ThirdPartyExt.SecretEnvPatterns[%d]", i), `use a wildcard such as "*TOKEN*"`, "invalid pattern %q, secrets matching it are not masked", p)
+		}
+	}
+
+	rclone := t.Rclone
+	if rclone.AutoMountEnable {
+		if strings.TrimSpace(rclone.AutoMountCommand) == "" {
+			v.warn("ThirdPartyExt.Rclone.AutoMountCommand", "add a mount command or set AutoMountEnable = false", "AutoMountEnable is true but the command is empty")
+		}
+		v.binPath("ThirdPartyExt.Rclone.BinPath", rclone.BinPath)
+	}
+	v.commandBlock("ThirdPartyExt.Rclone.AutoMountCommand", rclone.AutoMountCommand)
+	v.commandBlock("ThirdPartyExt.Rclone.AutoUnMountCommand", rclone.AutoUnMountCommand)
+	v.cron("ThirdPartyExt.Rclone.AutoReMountCron", rclone.AutoReMountCron)
+	v.nonNegative("ThirdPartyExt.Rclone.JobTimeoutSec", int64(rclone.JobTimeoutSec))
+	v.supervise("ThirdPartyExt.Rclone.Supervise", rclone.Supervise)
+
+	ddns := t.DdnsGO
+	if ddns.IsDDnsGOProxyEnable {
+		v.httpURL("ThirdPartyExt.DdnsGO.ReverseproxyUrl", ddns.ReverseproxyUrl, true)
+	}
+	if ddns.AutoStartEnable {
+		v.binPath("ThirdPartyExt.DdnsGO.BinPath", ddns.BinPath)
+	}
+	v.supervise("ThirdPartyExt.DdnsGO.Supervise", ddns.Supervise)
+
+	adg := t.AdGuard
+	if adg.IsAdGuardProxyEnable {
+		v.httpURL("ThirdPartyExt.AdGuard.ReverseproxyUrl", adg.ReverseproxyUrl, true)
+	}
+	if adg.AutoUpdateRulesEnable {
+		v.httpURL("ThirdPartyExt.AdGuard.Upstream_dns_fileUpdateUrl", adg.Upstream_dns_fileUpdateUrl, true)
+		if adg.Upstream_dns_file == "" {
+			v.fatal("ThirdPartyExt.AdGuard.Upstream_dns_file", "set the file the rules are saved to", "path is empty")
+		}
+		v.interval("ThirdPartyExt.AdGuard.AutoUpdateRulesInterval", adg.AutoUpdateRulesInterval, adg.AutoUpdateRulesCron, "AutoUpdateRulesCron")
+	}
+	v.cron("ThirdPartyExt.AdGuard.AutoUpdateRulesCron", adg.AutoUpdateRulesCron)
+	v.nonNegative("ThirdPartyExt.AdGuard.JobTimeoutSec", int64(adg.JobTimeoutSec))
+
+	lego := t.AcmeLego
+	if lego.IsLegoAutoRenew {
+		if lego.LEGO_PATH == "" {
+			v.fatal("ThirdPartyExt.AcmeLego.LEGO_PATH", "set the directory lego stores accounts and certificates in", "path is empty")
+		}
+		v.interval("ThirdPartyExt.AcmeLego.AutoUpdateCheckInterval", lego.AutoUpdateCheckInterval, lego.AutoUpdateCheckCron, "AutoUpdateCheckCron")
+		v.binPath("ThirdPartyExt.AcmeLego.BinPath", lego.BinPath)
+	}
+	v.commandBlock("ThirdPartyExt.AcmeLego.Command", lego.Command)
+	v.cron("ThirdPartyExt.AcmeLego.AutoUpdateCheckCron", lego.AutoUpdateCheckCron)
+	v.nonNegative("ThirdPartyExt.AcmeLego.JobTimeoutSec", int64(lego.JobTimeoutSec))
+	v.supervise("ThirdPartyExt.AcmeLego.Supervise", lego.Supervise)
+
+	if t.Caddy2.AutoStartEnable {
+		v.binPath("ThirdPartyExt.Caddy2.BinPath", t.Caddy2.BinPath)
+		if t.Caddy2.ConfigPath == "" {
+			v.fatal("ThirdPartyExt.Caddy2.ConfigPath", "point ConfigPath to a Caddyfile", "path is empty")
+		}
+	}
+	v.supervise("ThirdPartyExt.Caddy2.Supervise", t.Caddy2.Supervise)
+
+	if t.Openlist.AutoStartEnable {
+		v.binPath("ThirdPartyExt.Openlist.BinPath", t.Openlist.BinPath)
+	}
+	v.supervise("ThirdPartyExt.Openlist.Supervise", t.Openlist.Supervise)
+}
+
+// binPath 启用的第三方程序必须配置可执行文件，文件不存在时可以由自动下载补全，只提示
+func (v *validator) binPath(path, bin string) {
+	if bin == "" {
+		v.fatal(path, "set the path of the executable", "path is empty")
+		return
+	}
+	if _, err := os.Stat(bin); err != nil {
+		v.warn(path, "download the program or fix the path", "%v", err)
+	}
+}
+
+// commandBlock 命令块有语法错误时执行时一行也不会运行
+func (v *validator) commandBlock(path, script string) {
+	_, err := cmdline.Parse(script, cmdline.Options{KeepBackslash: runtime.GOOS == "windows", NoProcessEnv: true})
+	if err != nil {
+		v.fatal(path, "fix the quoting, a dry run of the block shows the parsed commands", "%v", err)
+	}
+}
+
+// superviseDeps 可以作为 After / Requires 的服务名
+var superviseDeps = []string{"rclone", "ddnsgo", "caddy2", "openlist", "extensions"}
+
+func (v *validator) supervise(p string, s SuperviseStru) {
+	switch strings.ToLower(strings.TrimSpace(s.RestartPolicy)) {
+	case "", "never", "on-failure", "always":
+	default:
+		v.fatal(p+".RestartPolicy", "use never, on-failure or always", "unknown restart policy %q", s.RestartPolicy)
+	}
+	v.nonNegative(p+".MaxRestarts", int64(s.MaxRestarts))
+	v.nonNegative(p+".BackoffInitialSec", int64(s.BackoffInitialSec))
+	if s.BackoffMaxSec > 0 && s.BackoffMaxSec < s.BackoffInitialSec {
+		v.warn(p+".BackoffMaxSec", "make BackoffMaxSec at least BackoffInitialSec", "BackoffMaxSec %d is less than BackoffInitialSec %d", s.BackoffMaxSec, s.BackoffInitialSec)
+	}
+	v.nonNegative(p+".StopGraceSec", int64(s.StopGraceSec))
+	v.nonNegative(p+".LogMaxSizeMB", int64(s.LogMaxSizeMB))
+	v.probe(p+".Liveness", s.Liveness)
+	v.probe(p+".Readiness", s.Readiness)
+	v.deps(p+".After", s.After)
+	v.deps(p+".Requires", s.Requires)
+	l := s.Limits
+	if l.Nice < -20 || l.Nice > 19 {
+		v.fatal(p+".Limits.Nice", "use a value between -20 and 19", "invalid nice %d", l.Nice)
+	}
+	if l.IONiceClass < 0 || l.IONiceClass > 3 {
+		v.fatal(p+".Limits.IONiceClass", "use 1 (realtime), 2 (best-effort) or 3 (idle)", "invalid class %d", l.IONiceClass)
+	}
+	if l.IONiceLevel < 0 || l.IONiceLevel > 7 {
+		v.fatal(p+".Limits.IONiceLevel", "use a value between 0 and 7", "invalid level %d", l.IONiceLevel)
+	}
+	v.nonNegative(p+".Limits.MemoryMaxMB", int64(l.MemoryMaxMB))
+	v.nonNegative(p+".Limits.CPUQuotaPercent", int64(l.CPUQuotaPercent))
+}
+
+func (v *validator) deps(p string, deps []string) {
+	for i, dep := range deps {
+		if strings.HasPrefix(dep, "mount:") || strings.HasPrefix(dep, "file:") || slices.Contains(superviseDeps, dep) {
+			continue
+		}
+		v.warn(fmt.Sprintf("%s[%d]", p, i), "use one of "+strings.Join(superviseDeps, ", ")+", mount:<path> or file:<path>", "unknown dependency %q is ignored", dep)
+	}
+}
+
+func (v *validator) probe(p string, pr ProbeStru) {
+	switch strings.ToLower(strings.TrimSpace(pr.Type)) {
+	case "":
+	case "http":
+		v.httpURL(p+".Target", pr.Target, true)
+	case "tcp", "unix", "exec":
+		if strings.TrimSpace(pr.Target) == "" {
+			v.fatal(p+".Target", "set the address, socket or command to probe", "probe type %s has no target", pr.Type)
+		} else if strings.EqualFold(strings.TrimSpace(pr.Type), "exec") {
+			v.commandBlock(p+".Target", pr.Target)
+		}
+	default:
+		v.fatal(p+".Type", "use http, tcp, unix, exec or leave it empty", "unknown probe type %q", pr.Type)
+	}
+}
+
+func (v *validator) events(e EventsStru) {
+	names := make(map[string]bool)
+	for i, wh := range e.Webhooks {
+		p := fmt.Sprintf("Events.Webhooks[%d]", i)
+		if wh.Name != "" && names[wh.Name] {
+			v.warn(p+".Name", "give every webhook a unique name", "duplicate name %q", wh.Name)
+		}
+		names[wh.Name] = true
+		if wh.Enable {
+			v.httpURL(p+".Url", wh.Url, true)
+		}
+		v.eventPatterns(p+".Events", wh.Events)
+		v.nonNegative(p+".MaxRetries", int64(wh.MaxRetries))
+		v.nonNegative(p+".TimeoutSec", int64(wh.TimeoutSec))
+	}
+}
+
+func (v *validator) eventPatterns(p string, patterns []string) {
+	for i, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.warn(fmt.Sprintf("%s[%d]", p, i), `use an event type or a wildcard such as "job.*"`, "invalid pattern %q never matches", pattern)
+		}
+	}
+}
+
+func (v *validator) notify(n NotifyStru) {
+	names := make(map[string]bool)
+	for i, ch := range n.Channels {
+		p := fmt.Sprintf("Notify.Channels[%d]", i)
+		if ch.Name == "" {
+			v.warn(p+".Name", "name the channel so routes and the test API can refer to it", "name is empty")
+		} else if names[ch.Name] {
+			v.warn(p+".Name", "give every channel a unique name", "duplicate name %q", ch.Name)
+		}
+		names[ch.Name] = true
+		if !ch.Enable {
+			continue
+		}
+		switch strings.ToLower(ch.Type) {
+		case "smtp":
+			if ch.SmtpHost == "" {
+				v.fatal(p+".SmtpHost", "set the mail server host", "smtp channel has no SmtpHost")
+			}
+			if ch.SmtpFrom == "" {
+				v.fatal(p+".SmtpFrom", "set the sender address", "smtp channel has no SmtpFrom")
+			}
+			if len(ch.SmtpTo) == 0 {
+				v.fatal(p+".SmtpTo", "add at least one recipient", "smtp channel has no recipients")
+			}
+			if ch.SmtpPort < 0 || ch.SmtpPort > 65535 {
+				v.port(p+".SmtpPort", ch.SmtpPort)
+			}
+		case "webhook":
+			v.httpURL(p+".Url", ch.Url, true)
+		case "bot":
+			if ch.BotToken == "" || ch.BotChatId == "" {
+				v.fatal(p, "set BotToken and BotChatId", "bot channel requires BotToken and BotChatId")
+			}
+			if ch.BotApiUrl != "" {
+				v.httpURL(p+".BotApiUrl", ch.BotApiUrl, true)
+			}
+		default:
+			v.fatal(p+".Type", "use smtp, webhook or bot", "unknown channel type %q", ch.Type)
+		}
+	}
+	for i, r := range n.Routes {
+		p := fmt.Sprintf("Notify.Routes[%d]", i)
+		v.eventPatterns(p+".Events", r.Events)
+		for j, name := range r.Channels {
+			if !names[name] {
+				v.warn(fmt.Sprintf("%s.Channels[%d]", p, j), "use the Name of a channel in Notify.Channels", "unknown channel %q", name)
+			}
+		}
+	}
+	v.nonNegative("Notify.RateLimitPerHour", int64(n.RateLimitPerHour))
+	v.nonNegative("Notify.DedupWindowMin", int64(n.DedupWindowMin))
+	v.cron("Notify.CertCheckCron", n.CertCheckCron)
+}
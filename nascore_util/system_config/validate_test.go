@@ -0,0 +1,126 @@
+package system_config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// validBase 没有任何问题的默认配置
+func validBase() *SysCfg {
+	cfg := NewDefaultConfig()
+	cfg.Server.DefaultStaticFileServiceEnable = false // 默认的 ./static/ 在测试目录下不存在
+	return cfg
+}
+
+// issueKeys 以 "severity path" 表示每个问题，排序后便于比较
+func issueKeys(issues ConfigIssues) []string {
+	keys := make([]string, 0, len(issues))
+	for _, i := range issues {
+		keys = append(keys, string(i.Severity)+" "+i.Path)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestValidateDefault(t *testing.T) {
+	if issues := validBase().Validate(); len(issues) != 0 {
+		t.Fatalf("default config has issues: %v", issues)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*SysCfg)
+		want   []string
+	}{
+		{"port out of range", func(c *SysCfg) { c.Server.HttpPort = 0 },
+			[]string{"fatal Server.httpPort"}},
+		{"https without certificates", func(c *SysCfg) {
+			c.Server.HttpsEnable = true
+			c.Server.HttpsPort = c.Server.HttpPort
+			c.Server.TlsCert, c.Server.TlsKey = "", ""
+		}, []string{"fatal Server.httpsPort", "fatal Server.tlscert", "fatal Server.tlskey"}},
+		{"unknown time zone", func(c *SysCfg) { c.Server.CronTimeZone = "Mars/Base" },
+			[]string{"warning Server.CronTimeZone"}},
+		{"prefix without slashes", func(c *SysCfg) {
+			c.Server.WebuiAndApiEnable = true
+			c.Server.WebUIPrefix = "ui"
+		}, []string{"warning Server.PrefixWebUI"}},
+		{"refresh token shorter than access token", func(c *SysCfg) {
+			c.JWT.UserAccessTokenExpires, c.JWT.UserRefreshTokenExpires = 100, 10
+		}, []string{"warning JWT.user_refresh_token_expires"}},
+		{"invalid secret pattern", func(c *SysCfg) { c.ThirdPartyExt.SecretEnvPatterns = []string{"*TOKEN*", "["} },
+			[]string{"warning ThirdPartyExt.SecretEnvPatterns[1]"}},
+		{"command block syntax", func(c *SysCfg) { c.ThirdPartyExt.Rclone.AutoMountCommand = "rclone mount 'a: /mnt" },
+			[]string{"fatal ThirdPartyExt.Rclone.AutoMountCommand"}},
+		{"cron expression", func(c *SysCfg) { c.ThirdPartyExt.Rclone.AutoReMountCron = "every day" },
+			[]string{"fatal ThirdPartyExt.Rclone.AutoReMountCron"}},
+		{"supervise", func(c *SysCfg) {
+			s := &c.ThirdPartyExt.Caddy2.Supervise
+			s.RestartPolicy = "sometimes"
+			s.BackoffInitialSec, s.BackoffMaxSec = 10, 5
+			s.Limits.Nice = 30
+			s.After = []string{"rclone", "mount:/mnt", "database"}
+		}, []string{
+			"fatal ThirdPartyExt.Caddy2.Supervise.Limits.Nice",
+			"fatal ThirdPartyExt.Caddy2.Supervise.RestartPolicy",
+			"warning ThirdPartyExt.Caddy2.Supervise.After[2]",
+			"warning ThirdPartyExt.Caddy2.Supervise.BackoffMaxSec",
+		}},
+		{"probes", func(c *SysCfg) {
+			s := &c.ThirdPartyExt.DdnsGO.Supervise
+			s.Liveness = ProbeStru{Type: "exec", Target: `check "unterminated`}
+			s.Readiness = ProbeStru{Type: "udp", Target: "127.0.0.1:53"}
+		}, []string{"fatal ThirdPartyExt.DdnsGO.Supervise.Liveness.Target", "fatal ThirdPartyExt.DdnsGO.Supervise.Readiness.Type"}},
+		{"webhooks", func(c *SysCfg) {
+			c.Events.Webhooks = []WebhookStru{
+				{Name: "a", Enable: true, Url: "ftp://example.com"},
+				{Name: "a", Events: []string{"job.*", "["}},
+			}
+		}, []string{"fatal Events.Webhooks[0].Url", "warning Events.Webhooks[1].Events[1]", "warning Events.Webhooks[1].Name"}},
+		{"notify", func(c *SysCfg) {
+			c.Notify.Channels = []NotifyChannelStru{
+				{Name: "mail", Enable: true, Type: "smtp"},
+				{Name: "off", Type: "carrier-pigeon"}, // 未启用的渠道不检查
+				{Name: "hook", Enable: true, Type: "pager"},
+			}
+			c.Notify.Routes = []NotifyRouteStru{{Events: []string{"job.*"}, Channels: []string{"mail", "nobody"}}}
+		}, []string{
+			"fatal Notify.Channels[0].SmtpFrom",
+			"fatal Notify.Channels[0].SmtpHost",
+			"fatal Notify.Channels[0].SmtpTo",
+			"fatal Notify.Channels[2].Type",
+			"warning Notify.Routes[0].Channels[1]",
+		}},
+	}
+	for _, tt := range tests {
+		cfg := validBase()
+		tt.mutate(cfg)
+		issues := cfg.Validate()
+		if got := issueKeys(issues); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: issues\n got %v\nwant %v", tt.name, got, tt.want)
+		}
+		for _, i := range issues {
+			if i.Message == "" || i.Suggestion == "" {
+				t.Errorf("%s: %s has no message or suggestion", tt.name, i.Path)
+			}
+		}
+	}
+}
+
+func TestIssuesErr(t *testing.T) {
+	warnings := ConfigIssues{{Path: "a", Severity: SeverityWarning, Message: "m"}}
+	if err := warnings.Err(); err != nil {
+		t.Fatalf("warnings only: Err = %v", err)
+	}
+	mixed := append(warnings, ConfigIssue{Path: "Server.httpPort", Severity: SeverityFatal, Message: "invalid port 0"})
+	var verr *ValidationError
+	if err := mixed.Err(); !errors.As(err, &verr) || len(verr.Issues) != 2 {
+		t.Fatalf("Err = %v", err)
+	}
+	if want := "invalid config, 1 fatal issue(s): [fatal] Server.httpPort: invalid port 0"; verr.Error() != want {
+		t.Fatalf("Error = %q, want %q", verr.Error(), want)
+	}
+}
@@ -12,11 +12,13 @@ import (
 	"github.com/pelletier/go-toml/v2"
 )
 
+// Export 把配置写入 exportConfigPath，权限为 0600。取自密钥文件的密钥会被清空，仍只保存在 Secret.SecretsFile 中
 func Export(cfg *system_config.SysCfg, exportConfigPath *string) error {
+	out := withoutFileSecrets(cfg)
 	var buf bytes.Buffer
 	encoder := toml.NewEncoder(&buf)
 	encoder.SetIndentTables(true)
-	err := encoder.Encode(cfg)
+	err := encoder.Encode(&out)
 	if err != nil {
 		log.Println("toml Encode err", err)
 		return err
@@ -27,11 +29,12 @@ func Export(cfg *system_config.SysCfg, exportConfigPath *string) error {
 		return err
 	}
 
-	err = os.WriteFile(*exportConfigPath, buf.Bytes(), 0644) // 0644 是文件权限
+	err = os.WriteFile(*exportConfigPath, buf.Bytes(), 0600) // 可能含有显式配置的密钥
 	if err != nil {
 		log.Printf("The first time to write to %s failed: %v", *exportConfigPath, err)
 		return err
 	}
+	os.Chmod(*exportConfigPath, 0600) // WriteFile 不会修改已存在文件的权限
 
 	content, err := os.ReadFile(*exportConfigPath)
 	if err != nil {
@@ -60,7 +63,7 @@ func Export(cfg *system_config.SysCfg, exportConfigPath *string) error {
 	})
 
 	if strings.Contains(string(content), "[Secret]") {
-		content = []byte(strings.Replace(string(content), "[Secret]", "# Some keys used for encryption will be automatically generated if they are empty, but this may cause the login status or password to become invalid after a restart\n[Secret]", 1))
+		content = []byte(strings.Replace(string(content), "[Secret]", "# Empty keys are generated randomly on first run and saved to SecretsFile (default nascore_secrets.toml next to this file, mode 0600). Keep that file, otherwise the login status and passwords become invalid\n[Secret]", 1))
 	}
 
 	// 通用处理所有多行字符串字段，去除首尾空行
@@ -79,7 +82,7 @@ func Export(cfg *system_config.SysCfg, exportConfigPath *string) error {
 	})
 
 	// 重新写入文件
-	err = os.WriteFile(*exportConfigPath, content, 0644)
+	err = os.WriteFile(*exportConfigPath, content, 0600)
 	if err != nil {
 		log.Printf("update TOML file %s failed: %v", *exportConfigPath, err)
 		return err
@@ -87,3 +90,19 @@ func Export(cfg *system_config.SysCfg, exportConfigPath *string) error {
 
 	return nil
 }
+
+// withoutFileSecrets 返回清空了密钥文件中密钥的副本，不修改 cfg（可能是已发布的只读快照）
+func withoutFileSecrets(cfg *system_config.SysCfg) system_config.SysCfg {
+	out := *cfg
+	for name, field := range map[string]*string{
+		system_config.SecretJwt:     &out.Secret.JwtSecret,
+		system_config.SecretSalt:    &out.Secret.Sha256HashSalt,
+		system_config.SecretAES:     &out.Secret.AESkey,
+		system_config.SecretWebhook: &out.Events.WebhookSecret,
+	} {
+		if cfg.Secret.FromSecretsFile(name) {
+			*field = ""
+		}
+	}
+	return out
+}
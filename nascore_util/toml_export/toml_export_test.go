@@ -0,0 +1,43 @@
+package toml_export
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nas-core/nascore/nascore_util/system_config"
+)
+
+func TestExportOmitsFileSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nascore.toml")
+	cfg, err := system_config.LoadConfig(path) // 新安装，密钥随机生成并保存到密钥文件
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := Export(cfg, &path); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{cfg.Secret.JwtSecret, cfg.Secret.Sha256HashSalt, cfg.Secret.AESkey, cfg.Events.WebhookSecret} {
+		if secret == "" || strings.Contains(string(data), secret) {
+			t.Errorf("exported config contains the generated secret %q", secret)
+		}
+	}
+	if fi, _ := os.Stat(path); runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Errorf("exported config mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	reloaded, err := system_config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig exported: %v", err)
+	}
+	r, c := reloaded.Secret, cfg.Secret
+	if r.JwtSecret != c.JwtSecret || r.Sha256HashSalt != c.Sha256HashSalt || r.AESkey != c.AESkey || reloaded.Events.WebhookSecret != cfg.Events.WebhookSecret {
+		t.Errorf("secrets changed after exporting and reloading")
+	}
+}